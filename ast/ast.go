@@ -2,6 +2,7 @@ package ast
 
 import (
 	"bytes"
+	"fmt"
 	"interrupter/token"
 	"interrupter/xlog"
 	"strings"
@@ -10,6 +11,8 @@ import (
 type Node interface {
 	String() string
 	TokenLiteral() string
+	// Span is the range of source the node was parsed from
+	Span() token.Span
 }
 
 type Statement interface {
@@ -37,11 +40,21 @@ func (p *Program) String() string {
 
 func (p *Program) TokenLiteral() string {
 	if len(p.Statements) > 0 {
-		return p.TokenLiteral()
+		return p.Statements[0].TokenLiteral()
 	}
 	return ""
 }
 
+func (p *Program) Span() token.Span {
+	if len(p.Statements) == 0 {
+		return token.Span{}
+	}
+	return token.Span{
+		Start: p.Statements[0].Span().Start,
+		End:   p.Statements[len(p.Statements)-1].Span().End,
+	}
+}
+
 type LetStatement struct {
 	Token token.Token
 	Name  *Identifier
@@ -65,6 +78,14 @@ func (l *LetStatement) TokenLiteral() string {
 	return l.Token.Literal
 }
 
+func (l *LetStatement) Span() token.Span {
+	end := l.Token.End
+	if l.Name != nil {
+		end = l.Name.Span().End
+	}
+	return token.Span{Start: l.Token.Pos, End: endOf(l.Value, end)}
+}
+
 func (l *LetStatement) statementNode() {}
 
 type Identifier struct {
@@ -80,6 +101,10 @@ func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
 
+func (i *Identifier) Span() token.Span {
+	return tokenSpan(i.Token)
+}
+
 func (i *Identifier) expressionNode() {}
 
 // 表达式分为中缀和前缀式
@@ -96,6 +121,10 @@ func (e *ExpressionStatement) TokenLiteral() string {
 	return e.Token.Literal
 }
 
+func (e *ExpressionStatement) Span() token.Span {
+	return token.Span{Start: e.Token.Pos, End: endOf(e.Expression, e.Token.End)}
+}
+
 func (e *ExpressionStatement) statementNode() {}
 
 type ReturnStatement struct {
@@ -117,6 +146,10 @@ func (r *ReturnStatement) TokenLiteral() string {
 	return r.Token.Literal
 }
 
+func (r *ReturnStatement) Span() token.Span {
+	return token.Span{Start: r.Token.Pos, End: endOf(r.ReturnValue, r.Token.End)}
+}
+
 func (r *ReturnStatement) statementNode() {}
 
 type Boolean struct {
@@ -127,6 +160,7 @@ type Boolean struct {
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
 func (b *Boolean) String() string       { return b.Token.Literal }
+func (b *Boolean) Span() token.Span     { return tokenSpan(b.Token) }
 
 type IntegerLiteral struct {
 	Token token.Token
@@ -136,6 +170,51 @@ type IntegerLiteral struct {
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
+func (il *IntegerLiteral) Span() token.Span     { return tokenSpan(il.Token) }
+
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+func (fl *FloatLiteral) Span() token.Span     { return tokenSpan(fl.Token) }
+
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (s *StringLiteral) expressionNode()      {}
+func (s *StringLiteral) TokenLiteral() string { return s.Token.Literal }
+func (s *StringLiteral) String() string       { return Quote(s.Value) }
+func (s *StringLiteral) Span() token.Span     { return tokenSpan(s.Token) }
+
+// Quote returns s as a string literal the lexer reads back to s
+func Quote(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"':
+			out.WriteString(`\"`)
+		case r == '\\':
+			out.WriteString(`\\`)
+		case r == '\n':
+			out.WriteString(`\n`)
+		case r == '\t':
+			out.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&out, `\u{%x}`, r)
+		default:
+			out.WriteRune(r)
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
 
 type PrefixExpression struct {
 	Token    token.Token
@@ -145,6 +224,9 @@ type PrefixExpression struct {
 
 func (p *PrefixExpression) expressionNode()      {}
 func (p *PrefixExpression) TokenLiteral() string { return p.Token.Literal }
+func (p *PrefixExpression) Span() token.Span {
+	return token.Span{Start: p.Token.Pos, End: endOf(p.Right, p.Token.End)}
+}
 func (p *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -163,6 +245,9 @@ type InfixExpression struct {
 
 func (i *InfixExpression) expressionNode()      {}
 func (i *InfixExpression) TokenLiteral() string { return i.Token.Literal }
+func (i *InfixExpression) Span() token.Span {
+	return token.Span{Start: startOf(i.Left, i.Token.Pos), End: endOf(i.Right, i.Token.End)}
+}
 func (i *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -173,16 +258,68 @@ func (i *InfixExpression) String() string {
 	return out.String()
 }
 
+// AssignExpression rebinds an identifier or stores into an index,
+// Operator is = or one of the compound forms like +=
+type AssignExpression struct {
+	Token    token.Token // the operator
+	Operator string
+	Target   Expression // Identifier or IndexExpression
+	Value    Expression
+}
+
+func (a *AssignExpression) expressionNode()      {}
+func (a *AssignExpression) TokenLiteral() string { return a.Token.Literal }
+func (a *AssignExpression) Span() token.Span {
+	return token.Span{Start: startOf(a.Target, a.Token.Pos), End: endOf(a.Value, a.Token.End)}
+}
+func (a *AssignExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(a.Target.String())
+	out.WriteString(" " + a.Operator + " ")
+	out.WriteString(a.Value.String())
+	out.WriteString(")")
+	return out.String()
+}
+
+// LogicalExpression is `a && b` or `a || b`, Right is evaluated only
+// when Left doesn't decide the result
+type LogicalExpression struct {
+	Token    token.Token // && or ||
+	Operator string
+	Left     Expression
+	Right    Expression
+}
+
+func (l *LogicalExpression) expressionNode()      {}
+func (l *LogicalExpression) TokenLiteral() string { return l.Token.Literal }
+func (l *LogicalExpression) Span() token.Span {
+	return token.Span{Start: startOf(l.Left, l.Token.Pos), End: endOf(l.Right, l.Token.End)}
+}
+func (l *LogicalExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(l.Left.String())
+	out.WriteString(" " + l.Operator + " ")
+	out.WriteString(l.Right.String())
+	out.WriteString(")")
+	return out.String()
+}
+
 type CallExpression struct {
-	Token     token.Token
-	Function  Expression // Identifier or FunctionLiteral
+	Token     token.Token // (
+	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
+	Rparent   token.Token // )
 }
 
 func (f *CallExpression) expressionNode() {}
 func (f *CallExpression) TokenLiteral() string {
 	return f.Token.Literal
 }
+func (f *CallExpression) Span() token.Span {
+	return token.Span{Start: startOf(f.Function, f.Token.Pos), End: f.Rparent.End}
+}
 func (f *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -197,3 +334,244 @@ func (f *CallExpression) String() string {
 	out.WriteString(")")
 	return out.String()
 }
+
+type BlockStatement struct {
+	Token      token.Token // {
+	Statements []Statement
+	Rbrace     token.Token // }
+}
+
+func (b *BlockStatement) statementNode()       {}
+func (b *BlockStatement) TokenLiteral() string { return b.Token.Literal }
+func (b *BlockStatement) Span() token.Span {
+	return token.Span{Start: b.Token.Pos, End: b.Rbrace.End}
+}
+func (b *BlockStatement) String() string {
+	var out bytes.Buffer
+	for _, s := range b.Statements {
+		out.WriteString(s.String())
+	}
+	return out.String()
+}
+
+type IfExpression struct {
+	Token       token.Token // if
+	Condition   Expression
+	Consequence *BlockStatement
+	Alternative *BlockStatement
+}
+
+func (i *IfExpression) expressionNode()      {}
+func (i *IfExpression) TokenLiteral() string { return i.Token.Literal }
+func (i *IfExpression) Span() token.Span {
+	end := i.Token.End
+	if i.Alternative != nil {
+		end = i.Alternative.Span().End
+	} else if i.Consequence != nil {
+		end = i.Consequence.Span().End
+	}
+	return token.Span{Start: i.Token.Pos, End: end}
+}
+func (i *IfExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("if")
+	out.WriteString(i.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(i.Consequence.String())
+	if i.Alternative != nil {
+		out.WriteString("else ")
+		out.WriteString(i.Alternative.String())
+	}
+	return out.String()
+}
+
+// WhileStatement runs Body as long as Condition is truthy
+type WhileStatement struct {
+	Token     token.Token // while
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (w *WhileStatement) statementNode()       {}
+func (w *WhileStatement) TokenLiteral() string { return w.Token.Literal }
+func (w *WhileStatement) Span() token.Span {
+	return token.Span{Start: w.Token.Pos, End: endOf(w.Body, w.Token.End)}
+}
+func (w *WhileStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("while (")
+	out.WriteString(w.Condition.String())
+	out.WriteString(") ")
+	out.WriteString(w.Body.String())
+	return out.String()
+}
+
+// ForStatement runs Body once for every element of Iterable, bound to Variable
+type ForStatement struct {
+	Token    token.Token // for
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (f *ForStatement) statementNode()       {}
+func (f *ForStatement) TokenLiteral() string { return f.Token.Literal }
+func (f *ForStatement) Span() token.Span {
+	return token.Span{Start: f.Token.Pos, End: endOf(f.Body, f.Token.End)}
+}
+func (f *ForStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("for (")
+	out.WriteString(f.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(f.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(f.Body.String())
+	return out.String()
+}
+
+type BreakStatement struct {
+	Token token.Token // break
+}
+
+func (b *BreakStatement) statementNode()       {}
+func (b *BreakStatement) TokenLiteral() string { return b.Token.Literal }
+func (b *BreakStatement) Span() token.Span     { return tokenSpan(b.Token) }
+func (b *BreakStatement) String() string       { return b.Token.Literal }
+
+type ContinueStatement struct {
+	Token token.Token // continue
+}
+
+func (c *ContinueStatement) statementNode()       {}
+func (c *ContinueStatement) TokenLiteral() string { return c.Token.Literal }
+func (c *ContinueStatement) Span() token.Span     { return tokenSpan(c.Token) }
+func (c *ContinueStatement) String() string       { return c.Token.Literal }
+
+type FunctionLiteral struct {
+	Token      token.Token // fn
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (f *FunctionLiteral) expressionNode()      {}
+func (f *FunctionLiteral) TokenLiteral() string { return f.Token.Literal }
+func (f *FunctionLiteral) Span() token.Span {
+	end := f.Token.End
+	if f.Body != nil {
+		end = f.Body.Span().End
+	}
+	return token.Span{Start: f.Token.Pos, End: end}
+}
+func (f *FunctionLiteral) String() string {
+	var out bytes.Buffer
+
+	params := make([]string, 0, len(f.Parameters))
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(f.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	out.WriteString(f.Body.String())
+	return out.String()
+}
+
+func tokenSpan(t token.Token) token.Span {
+	return token.Span{Start: t.Pos, End: t.End}
+}
+
+// startOf and endOf fall back to def for nodes missing after a parse error
+func startOf(n Node, def token.Position) token.Position {
+	if n == nil {
+		return def
+	}
+	return n.Span().Start
+}
+
+func endOf(n Node, def token.Position) token.Position {
+	if n == nil {
+		return def
+	}
+	return n.Span().End
+}
+
+type ArrayLiteral struct {
+	Token    token.Token // [
+	Elements []Expression
+	Rbracket token.Token // ]
+}
+
+func (a *ArrayLiteral) expressionNode()      {}
+func (a *ArrayLiteral) TokenLiteral() string { return a.Token.Literal }
+func (a *ArrayLiteral) Span() token.Span {
+	return token.Span{Start: a.Token.Pos, End: a.Rbracket.End}
+}
+func (a *ArrayLiteral) String() string {
+	var out bytes.Buffer
+
+	elements := make([]string, 0, len(a.Elements))
+	for _, el := range a.Elements {
+		elements = append(elements, el.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+type IndexExpression struct {
+	Token    token.Token // [
+	Left     Expression
+	Index    Expression
+	Rbracket token.Token // ]
+}
+
+func (i *IndexExpression) expressionNode()      {}
+func (i *IndexExpression) TokenLiteral() string { return i.Token.Literal }
+func (i *IndexExpression) Span() token.Span {
+	return token.Span{Start: startOf(i.Left, i.Token.Pos), End: i.Rbracket.End}
+}
+func (i *IndexExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(i.Left.String())
+	out.WriteString("[")
+	out.WriteString(i.Index.String())
+	out.WriteString("])")
+	return out.String()
+}
+
+// HashPair is a key: value entry of a HashLiteral
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+type HashLiteral struct {
+	Token  token.Token // {
+	Pairs  []HashPair  // in source order
+	Rbrace token.Token // }
+}
+
+func (h *HashLiteral) expressionNode()      {}
+func (h *HashLiteral) TokenLiteral() string { return h.Token.Literal }
+func (h *HashLiteral) Span() token.Span {
+	return token.Span{Start: h.Token.Pos, End: h.Rbrace.End}
+}
+func (h *HashLiteral) String() string {
+	var out bytes.Buffer
+
+	pairs := make([]string, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
@@ -0,0 +1,122 @@
+// Package cst is a lossless concrete syntax tree: it holds every token of
+// the source, with its whitespace and comments, so that printing the tree
+// gives back the source byte for byte. Every node points to the ast node
+// it was built from, which makes it easy to find the syntax to edit.
+package cst
+
+import (
+	"interrupter/ast"
+	"interrupter/token"
+	"io"
+	"strings"
+)
+
+// Element is a *Node or a *Token
+type Element interface {
+	element()
+	writeTo(sb *strings.Builder)
+}
+
+// Token is a token as written in the source
+type Token struct {
+	token.Token
+	// Text is the token as written, it differs from Literal for strings.
+	// it may be edited, String then prints the new text.
+	Text string
+}
+
+func (t *Token) element() {}
+
+func (t *Token) writeTo(sb *strings.Builder) {
+	for _, tr := range t.Leading {
+		sb.WriteString(tr.Literal)
+	}
+	sb.WriteString(t.Text)
+	for _, tr := range t.Trailing {
+		sb.WriteString(tr.Literal)
+	}
+}
+
+// Node is a piece of syntax with its tokens and child nodes in source order
+type Node struct {
+	// AST is the ast node this one stands for, for a parenthesized
+	// expression it is the expression inside the parentheses
+	AST ast.Node
+	// Paren marks the ( ) around an expression, the expression itself is
+	// the child node between the parentheses
+	Paren    bool
+	Children []Element
+}
+
+func (n *Node) element() {}
+
+func (n *Node) writeTo(sb *strings.Builder) {
+	for _, c := range n.Children {
+		c.writeTo(sb)
+	}
+}
+
+// String returns the source text of n, trivia included
+func (n *Node) String() string {
+	var sb strings.Builder
+	n.writeTo(&sb)
+	return sb.String()
+}
+
+// WriteTo writes the source text of n to w
+func (n *Node) WriteTo(w io.Writer) (int64, error) {
+	m, err := io.WriteString(w, n.String())
+	return int64(m), err
+}
+
+// Tokens returns the tokens of n in source order
+func (n *Node) Tokens() []*Token {
+	var tokens []*Token
+	n.Walk(func(e Element) bool {
+		if t, ok := e.(*Token); ok {
+			tokens = append(tokens, t)
+		}
+		return true
+	})
+	return tokens
+}
+
+// Walk calls fn for n and everything below it in source order, the
+// children of a node are skipped when fn returns false for it
+func (n *Node) Walk(fn func(Element) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, c := range n.Children {
+		if child, ok := c.(*Node); ok {
+			child.Walk(fn)
+		} else {
+			fn(c)
+		}
+	}
+}
+
+// Find returns the node standing for an ast node, or nil. of a
+// parenthesized expression the inner node is returned.
+func (n *Node) Find(target ast.Node) *Node {
+	var found *Node
+	n.Walk(func(e Element) bool {
+		if found != nil {
+			return false
+		}
+		if node, ok := e.(*Node); ok && node.AST == target && !node.Paren {
+			found = node
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// Program converts the tree of a whole file to its ast. the ast is the
+// one the tree was built from, so it doesn't see edits made to the
+// tokens, parse the String of the tree again for that.
+func (n *Node) Program() *ast.Program {
+	prog, _ := n.AST.(*ast.Program)
+	return prog
+}
@@ -0,0 +1,57 @@
+package diag
+
+import (
+	"fmt"
+	"interrupter/token"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Note:
+		return "note"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Fix is an edit that would resolve a diagnostic: replace Span with Replacement
+type Fix struct {
+	Message     string
+	Span        token.Span
+	Replacement string
+}
+
+// Diagnostic is a problem found in the source by the lexer, parser or evaluator
+type Diagnostic struct {
+	Severity Severity
+	Code     string // stable id of the kind of problem, e.g. P001
+	Message  string
+	Span     token.Span
+	Notes    []string
+	Fix      *Fix
+}
+
+// position: message, the short form used by Parser.Errors
+func (d Diagnostic) String() string {
+	return d.Span.Start.String() + ": " + d.Message
+}
+
+func Errorf(code string, span token.Span, format string, a ...any) Diagnostic {
+	return Diagnostic{
+		Severity: Error,
+		Code:     code,
+		Message:  fmt.Sprintf(format, a...),
+		Span:     span,
+	}
+}
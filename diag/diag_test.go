@@ -0,0 +1,91 @@
+package diag
+
+import (
+	"bytes"
+	"interrupter/token"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func span(line, startCol, endCol int) token.Span {
+	return token.Span{
+		Start: token.Position{Line: line, Column: startCol, Offset: startCol - 1},
+		End:   token.Position{Line: line, Column: endCol, Offset: endCol - 1},
+	}
+}
+
+func TestRender(t *testing.T) {
+	source := "let a = 1;\n\tlet b = a + true;"
+	d := Errorf("R001", span(2, 10, 18), "type mismatch: %s + %s", "INTEGER", "BOOLEAN")
+	d.Notes = []string{"a is bound at 1:5"}
+	d.Fix = &Fix{Message: "remove the operand", Span: span(2, 10, 18)}
+
+	var out bytes.Buffer
+	r := &Renderer{}
+	r.Render(&out, source, d)
+
+	expected := "2:10: error[R001]: type mismatch: INTEGER + BOOLEAN\n" +
+		"  |\n" +
+		"2 | \tlet b = a + true;\n" +
+		"  | \t        ^~~~~~~~\n" +
+		"  = note: a is bound at 1:5\n" +
+		"  = help: remove the operand\n"
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRenderUnicode(t *testing.T) {
+	source := `let 名字 = "é" + 1;`
+	d := Errorf("R001", span(1, 10, 17), "type mismatch: STRING + INTEGER")
+
+	var out bytes.Buffer
+	r := &Renderer{}
+	r.Render(&out, source, d)
+
+	expected := "1:10: error[R001]: type mismatch: STRING + INTEGER\n" +
+		"  |\n" +
+		"1 | let 名字 = \"é\" + 1;\n" +
+		"  |            ^~~~~~~\n"
+	assert.Equal(t, expected, out.String())
+
+	// the wide characters themselves get two cells each
+	out.Reset()
+	r.Render(&out, source, Errorf("R003", span(1, 5, 7), "identifier not found: 名字"))
+	expected = "1:5: error[R003]: identifier not found: 名字\n" +
+		"  |\n" +
+		"1 | let 名字 = \"é\" + 1;\n" +
+		"  |     ^~~~\n"
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRenderWithoutSource(t *testing.T) {
+	var out bytes.Buffer
+	r := &Renderer{}
+	r.Render(&out, "", Errorf("P001", span(1, 3, 3), "unexpected"))
+	assert.Equal(t, "1:3: error[P001]: unexpected\n", out.String())
+}
+
+func TestRenderColor(t *testing.T) {
+	var out bytes.Buffer
+	r := &Renderer{Color: true}
+	r.Render(&out, "x", Errorf("", span(1, 1, 2), "oops"))
+	assert.Contains(t, out.String(), ansiRed)
+	assert.Contains(t, out.String(), ansiReset)
+}
+
+func TestWriteJSON(t *testing.T) {
+	d := Errorf("P001", span(1, 9, 9), "expected next token to be ), got EOF instead")
+	d.Fix = &Fix{Message: "insert )", Span: span(1, 9, 9), Replacement: ")"}
+
+	var out bytes.Buffer
+	assert.NoError(t, WriteJSON(&out, []Diagnostic{d}))
+
+	expected := `[{"severity":"error","code":"P001","message":"expected next token to be ), got EOF instead",` +
+		`"span":{"start":{"offset":8,"line":1,"column":9},"end":{"offset":8,"line":1,"column":9}},` +
+		`"fix":{"message":"insert )","span":{"start":{"offset":8,"line":1,"column":9},"end":{"offset":8,"line":1,"column":9}},"replacement":")"}}]` + "\n"
+	assert.Equal(t, expected, out.String())
+
+	out.Reset()
+	assert.NoError(t, WriteJSON(&out, nil))
+	assert.Equal(t, "[]\n", out.String())
+}
@@ -0,0 +1,75 @@
+package diag
+
+import (
+	"encoding/json"
+	"interrupter/token"
+	"io"
+)
+
+// the JSON form is meant for editors, keep it stable
+
+type jsonPosition struct {
+	Filename string `json:"filename,omitempty"`
+	Offset   int    `json:"offset"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+type jsonSpan struct {
+	Start jsonPosition `json:"start"`
+	End   jsonPosition `json:"end"`
+}
+
+type jsonFix struct {
+	Message     string   `json:"message"`
+	Span        jsonSpan `json:"span"`
+	Replacement string   `json:"replacement"`
+}
+
+type jsonDiagnostic struct {
+	Severity string   `json:"severity"`
+	Code     string   `json:"code,omitempty"`
+	Message  string   `json:"message"`
+	Span     jsonSpan `json:"span"`
+	Notes    []string `json:"notes,omitempty"`
+	Fix      *jsonFix `json:"fix,omitempty"`
+}
+
+func (d Diagnostic) MarshalJSON() ([]byte, error) {
+	jd := jsonDiagnostic{
+		Severity: d.Severity.String(),
+		Code:     d.Code,
+		Message:  d.Message,
+		Span:     toJSONSpan(d.Span),
+		Notes:    d.Notes,
+	}
+	if d.Fix != nil {
+		jd.Fix = &jsonFix{
+			Message:     d.Fix.Message,
+			Span:        toJSONSpan(d.Fix.Span),
+			Replacement: d.Fix.Replacement,
+		}
+	}
+	return json.Marshal(jd)
+}
+
+// WriteJSON writes ds as a JSON array
+func WriteJSON(w io.Writer, ds []Diagnostic) error {
+	if ds == nil {
+		ds = []Diagnostic{}
+	}
+	return json.NewEncoder(w).Encode(ds)
+}
+
+func toJSONSpan(s token.Span) jsonSpan {
+	return jsonSpan{Start: toJSONPosition(s.Start), End: toJSONPosition(s.End)}
+}
+
+func toJSONPosition(p token.Position) jsonPosition {
+	return jsonPosition{
+		Filename: p.Filename,
+		Offset:   p.Offset,
+		Line:     p.Line,
+		Column:   p.Column,
+	}
+}
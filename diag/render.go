@@ -0,0 +1,172 @@
+package diag
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+	ansiCyan   = "\x1b[36m"
+	ansiBlue   = "\x1b[34m"
+)
+
+// Renderer prints diagnostics for humans:
+//
+//	1:9: error[P001]: expected next token to be ), got EOF instead
+//	  |
+//	1 | add(1, 2
+//	  |         ^
+//	  = help: insert )
+type Renderer struct {
+	// Color turns on ANSI escape codes
+	Color bool
+}
+
+// Render writes ds, source is the text the spans point into and may be
+// empty, then the snippet is left out.
+func (r *Renderer) Render(w io.Writer, source string, ds ...Diagnostic) {
+	for _, d := range ds {
+		r.render(w, source, d)
+	}
+}
+
+func (r *Renderer) render(w io.Writer, source string, d Diagnostic) {
+	sevColor := r.severityColor(d.Severity)
+	title := d.Severity.String()
+	if d.Code != "" {
+		title += "[" + d.Code + "]"
+	}
+	fmt.Fprintf(w, "%s: %s: %s\n",
+		d.Span.Start, r.paint(ansiBold+sevColor, title), r.paint(ansiBold, d.Message))
+
+	line, ok := sourceLine(source, d.Span.Start.Line)
+	gutter := ""
+	if ok {
+		num := strconv.Itoa(d.Span.Start.Line)
+		gutter = strings.Repeat(" ", len(num))
+		fmt.Fprintf(w, "%s %s\n", gutter, r.paint(ansiBlue, "|"))
+		fmt.Fprintf(w, "%s %s %s\n", r.paint(ansiBlue, num), r.paint(ansiBlue, "|"), line)
+		fmt.Fprintf(w, "%s %s %s\n", gutter, r.paint(ansiBlue, "|"),
+			r.paint(sevColor, underline(line, d)))
+	}
+
+	for _, n := range d.Notes {
+		fmt.Fprintf(w, "%s %s note: %s\n", gutter, r.paint(ansiBlue, "="), n)
+	}
+	if d.Fix != nil {
+		fmt.Fprintf(w, "%s %s help: %s\n", gutter, r.paint(ansiBlue, "="), d.Fix.Message)
+	}
+}
+
+// underline builds the ^~~~ marker line, the padding copies the tabs of line
+// so the marker stays aligned. columns count runes, not bytes, and the
+// padding and marker take as many cells as the runes above them.
+func underline(line string, d Diagnostic) string {
+	runes := []rune(line)
+	start := d.Span.Start.Column - 1
+	if start < 0 {
+		start = 0
+	}
+	if start > len(runes) {
+		start = len(runes)
+	}
+	width := 1
+	if d.Span.End.Line == d.Span.Start.Line && d.Span.End.Column > d.Span.Start.Column {
+		width = d.Span.End.Column - d.Span.Start.Column
+	} else if d.Span.End.Line > d.Span.Start.Line && len(runes) > start {
+		// multi-line spans are marked to the end of their first line
+		width = len(runes) - start
+	}
+
+	if end := start + width; end <= len(runes) {
+		width = 0
+		for _, c := range runes[start:end] {
+			width += cellWidth(c)
+		}
+		if width < 1 {
+			width = 1
+		}
+	}
+
+	var out strings.Builder
+	for _, c := range runes[:start] {
+		if c == '\t' {
+			out.WriteByte('\t')
+		} else {
+			out.WriteString(strings.Repeat(" ", cellWidth(c)))
+		}
+	}
+	out.WriteByte('^')
+	out.WriteString(strings.Repeat("~", width-1))
+	return out.String()
+}
+
+// East Asian wide and fullwidth characters, they take two terminal cells
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F},   // Hangul Jamo
+	{0x2E80, 0x303E},   // CJK radicals, punctuation
+	{0x3041, 0x33FF},   // kana, CJK symbols
+	{0x3400, 0x4DBF},   // CJK extension A
+	{0x4E00, 0x9FFF},   // CJK unified ideographs
+	{0xA000, 0xA4CF},   // Yi
+	{0xAC00, 0xD7A3},   // Hangul syllables
+	{0xF900, 0xFAFF},   // CJK compatibility ideographs
+	{0xFE30, 0xFE4F},   // CJK compatibility forms
+	{0xFF00, 0xFF60},   // fullwidth forms
+	{0xFFE0, 0xFFE6},   // fullwidth signs
+	{0x1F300, 0x1F64F}, // emoji
+	{0x1F900, 0x1F9FF}, // emoji
+	{0x20000, 0x2FFFD}, // CJK extensions B and on
+	{0x30000, 0x3FFFD},
+}
+
+// cellWidth is the number of terminal cells c takes, combining marks
+// take none
+func cellWidth(c rune) int {
+	if unicode.Is(unicode.Mn, c) {
+		return 0
+	}
+	for _, r := range wideRanges {
+		if c >= r[0] && c <= r[1] {
+			return 2
+		}
+	}
+	return 1
+}
+
+// sourceLine returns line n (from 1) of source without the line break
+func sourceLine(source string, n int) (string, bool) {
+	if source == "" || n < 1 {
+		return "", false
+	}
+	lines := strings.Split(source, "\n")
+	if n > len(lines) {
+		return "", false
+	}
+	return strings.TrimRight(lines[n-1], "\r"), true
+}
+
+func (r *Renderer) severityColor(s Severity) string {
+	switch s {
+	case Error:
+		return ansiRed
+	case Warning:
+		return ansiYellow
+	default:
+		return ansiCyan
+	}
+}
+
+func (r *Renderer) paint(code, s string) string {
+	if !r.Color {
+		return s
+	}
+	return code + s + ansiReset
+}
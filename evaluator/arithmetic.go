@@ -0,0 +1,55 @@
+package evaluator
+
+import "math"
+
+// checked int64 arithmetic, ok is false when the result wrapped around
+
+func addInt64(a, b int64) (int64, bool) {
+	r := a + b
+	if (a > 0 && b > 0 && r < 0) || (a < 0 && b < 0 && r >= 0) {
+		return r, false
+	}
+	return r, true
+}
+
+func subInt64(a, b int64) (int64, bool) {
+	r := a - b
+	if (a >= 0 && b < 0 && r < 0) || (a < 0 && b > 0 && r >= 0) {
+		return r, false
+	}
+	return r, true
+}
+
+func mulInt64(a, b int64) (int64, bool) {
+	r := a * b
+	if a == 0 || b == 0 {
+		return r, true
+	}
+	// MinInt64 * -1 wraps back to MinInt64, which the division check can't see
+	if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+		return r, false
+	}
+	return r, r/b == a
+}
+
+// the caller must rule out b == 0
+func divInt64(a, b int64) (int64, bool) {
+	if a == math.MinInt64 && b == -1 {
+		return a / b, false
+	}
+	return a / b, true
+}
+
+func negInt64(a int64) (int64, bool) {
+	return -a, a != math.MinInt64
+}
+
+// the caller must rule out a negative n, bits shifted out or into the sign
+// bit are an overflow
+func shlInt64(a, n int64) (int64, bool) {
+	r := a << n
+	if n >= 64 {
+		return r, a == 0
+	}
+	return r, r>>n == a
+}
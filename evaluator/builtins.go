@@ -0,0 +1,144 @@
+package evaluator
+
+import (
+	"fmt"
+	"interrupter/object"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+// builtins are looked up when an identifier isn't bound in the environment,
+// so scripts may shadow them
+func (e *Evaluator) builtin(name string) (*object.Builtin, bool) {
+	if e.builtins == nil {
+		e.builtins = e.newBuiltins()
+	}
+	b, ok := e.builtins[name]
+	return b, ok
+}
+
+func (e *Evaluator) newBuiltins() map[string]*object.Builtin {
+	fns := map[string]object.BuiltinFunction{
+		"len":   builtinLen,
+		"puts":  e.builtinPuts,
+		"first": builtinFirst,
+		"last":  builtinLast,
+		"rest":  builtinRest,
+		"push":  builtinPush,
+		"type":  builtinType,
+	}
+	builtins := make(map[string]*object.Builtin, len(fns))
+	for name, fn := range fns {
+		builtins[name] = &object.Builtin{Name: name, Fn: fn}
+	}
+	return builtins
+}
+
+func (e *Evaluator) out() io.Writer {
+	if e.Out == nil {
+		return os.Stdout
+	}
+	return e.Out
+}
+
+// len counts the characters of a string, not its bytes
+func builtinLen(args ...object.Object) object.Object {
+	if err := checkArgCount("len", args, 1); err != nil {
+		return err
+	}
+	switch arg := args[0].(type) {
+	case *object.String:
+		return &object.IntegerObject{Value: int64(utf8.RuneCountInString(arg.Value))}
+	case *object.Array:
+		return &object.IntegerObject{Value: int64(len(arg.Elements))}
+	case *object.Hash:
+		return &object.IntegerObject{Value: int64(arg.Len())}
+	default:
+		return newError(codeArgumentType, "argument to `len` not supported, got %s", args[0].Type())
+	}
+}
+
+// puts writes every argument on its own line
+func (e *Evaluator) builtinPuts(args ...object.Object) object.Object {
+	for _, arg := range args {
+		fmt.Fprintln(e.out(), arg.Inspect())
+	}
+	return object.NULL
+}
+
+func builtinFirst(args ...object.Object) object.Object {
+	array, err := arrayArg("first", args, 1)
+	if err != nil {
+		return err
+	}
+	if len(array.Elements) == 0 {
+		return object.NULL
+	}
+	return array.Elements[0]
+}
+
+func builtinLast(args ...object.Object) object.Object {
+	array, err := arrayArg("last", args, 1)
+	if err != nil {
+		return err
+	}
+	if len(array.Elements) == 0 {
+		return object.NULL
+	}
+	return array.Elements[len(array.Elements)-1]
+}
+
+// rest returns a new array without the first element
+func builtinRest(args ...object.Object) object.Object {
+	array, err := arrayArg("rest", args, 1)
+	if err != nil {
+		return err
+	}
+	if len(array.Elements) == 0 {
+		return object.NULL
+	}
+	elements := make([]object.Object, len(array.Elements)-1)
+	copy(elements, array.Elements[1:])
+	return &object.Array{Elements: elements}
+}
+
+// push returns a new array, the argument is left untouched
+func builtinPush(args ...object.Object) object.Object {
+	array, err := arrayArg("push", args, 2)
+	if err != nil {
+		return err
+	}
+	elements := make([]object.Object, len(array.Elements), len(array.Elements)+1)
+	copy(elements, array.Elements)
+	elements = append(elements, args[1])
+	return &object.Array{Elements: elements}
+}
+
+func builtinType(args ...object.Object) object.Object {
+	if err := checkArgCount("type", args, 1); err != nil {
+		return err
+	}
+	return &object.String{Value: string(args[0].Type())}
+}
+
+func checkArgCount(name string, args []object.Object, want int) *object.Error {
+	if len(args) != want {
+		return newError(codeArgumentCount, "wrong number of arguments to `%s`: want=%d, got=%d",
+			name, want, len(args))
+	}
+	return nil
+}
+
+// arrayArg checks the argument count and that the first argument is an array
+func arrayArg(name string, args []object.Object, want int) (*object.Array, *object.Error) {
+	if err := checkArgCount(name, args, want); err != nil {
+		return nil, err
+	}
+	array, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, newError(codeArgumentType, "argument to `%s` must be %s, got %s",
+			name, object.ARRAY_OBJ, args[0].Type())
+	}
+	return array, nil
+}
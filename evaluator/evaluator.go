@@ -1,161 +1,893 @@
 package evaluator
 
 import (
+	"fmt"
 	"interrupter/ast"
 	"interrupter/object"
 	"interrupter/xlog"
+	"io"
+	"math"
+	"strings"
 )
 
-func Eval(node ast.Node) object.Object {
+// diagnostic codes of runtime errors
+const (
+	codeTypeMismatch      = "R001"
+	codeUnknownOperator   = "R002"
+	codeUnknownIdentifier = "R003"
+	codeNotFunction       = "R004"
+	codeArgumentCount     = "R005"
+	codeDivisionByZero    = "R006"
+	codeIntegerOverflow   = "R007"
+	codeIndexOutOfRange   = "R008"
+	codeInvalidIndex      = "R009"
+	codeUnhashableKey     = "R010"
+	codeArgumentType      = "R011"
+	codeNegativeShift     = "R012"
+	codeOutsideLoop       = "R013"
+	codeNotIterable       = "R014"
+	codeUndeclared        = "R015"
+)
+
+// Evaluator walks the ast and evaluates it, the zero value is ready to use
+type Evaluator struct {
+	// CheckOverflow makes int64 overflow a runtime error instead of wrapping around
+	CheckOverflow bool
+	// Out is where puts writes to, os.Stdout if nil
+	Out io.Writer
+
+	builtins map[string]*object.Builtin
+}
+
+func New() *Evaluator {
+	return &Evaluator{}
+}
+
+// Eval evaluates node with the default settings
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New().Eval(node, env)
+}
+
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	obj := e.eval(node, env)
+	// the innermost node an error comes out of is the one that failed
+	if err, ok := obj.(*object.Error); ok && !err.Span.Start.IsValid() {
+		err.Span = node.Span()
+	}
+	return obj
+}
+
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
 	switch n := node.(type) {
 	case *ast.Boolean:
 		return object.TrueOrFase(n.Value)
 	case *ast.IntegerLiteral:
 		return &object.IntegerObject{Value: n.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: n.Value}
+	case *ast.StringLiteral:
+		return &object.String{Value: n.Value}
+	case *ast.Identifier:
+		return e.evalIdentifier(n, env)
 	case *ast.PrefixExpression:
-		right := Eval(n.Right)
-		return evalPrefixExpr(n.Operator, right)
+		right := e.Eval(n.Right, env)
+		if interrupted(right) {
+			return right
+		}
+		return e.evalPrefixExpr(n.Operator, right)
 	case *ast.InfixExpression:
-		left, right := Eval(n.Left), Eval(n.Right)
-		return evalInfixExpr(n.Operator, left, right)
+		left := e.Eval(n.Left, env)
+		if interrupted(left) {
+			return left
+		}
+		right := e.Eval(n.Right, env)
+		if interrupted(right) {
+			return right
+		}
+		return e.evalInfixExpr(n.Operator, left, right)
+	case *ast.AssignExpression:
+		return e.evalAssignExpr(n, env)
+	case *ast.LogicalExpression:
+		return e.evalLogicalExpr(n, env)
+	case *ast.IfExpression:
+		return e.evalIfExpr(n, env)
+	case *ast.BlockStatement:
+		return e.evalBlockStatement(n, env)
+	case *ast.ReturnStatement:
+		if n.ReturnValue == nil {
+			return &object.ReturnValue{Value: object.NULL}
+		}
+		val := e.Eval(n.ReturnValue, env)
+		if interrupted(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+	case *ast.FunctionLiteral:
+		return &object.Function{Parameters: n.Parameters, Body: n.Body, Env: env}
+	case *ast.CallExpression:
+		fn := e.Eval(n.Function, env)
+		if interrupted(fn) {
+			return fn
+		}
+		args := e.evalExpressions(n.Arguments, env)
+		if len(args) == 1 && interrupted(args[0]) {
+			return args[0]
+		}
+		return e.applyFunction(fn, args)
+	case *ast.ArrayLiteral:
+		elements := e.evalExpressions(n.Elements, env)
+		if len(elements) == 1 && interrupted(elements[0]) {
+			return elements[0]
+		}
+		return &object.Array{Elements: elements}
+	case *ast.HashLiteral:
+		return e.evalHashLiteral(n, env)
+	case *ast.IndexExpression:
+		left := e.Eval(n.Left, env)
+		if interrupted(left) {
+			return left
+		}
+		index := e.Eval(n.Index, env)
+		if interrupted(index) {
+			return index
+		}
+		return evalIndexExpr(left, index)
+	case *ast.WhileStatement:
+		return e.evalWhileStatement(n, env)
+	case *ast.ForStatement:
+		return e.evalForStatement(n, env)
+	case *ast.BreakStatement:
+		return object.BREAK
+	case *ast.ContinueStatement:
+		return object.CONTINUE
+	case *ast.LetStatement:
+		val := e.Eval(n.Value, env)
+		if interrupted(val) {
+			return val
+		}
+		env.Define(n.Name.Value, val)
 	case *ast.ExpressionStatement:
-		return Eval(n.Expression)
+		return e.Eval(n.Expression, env)
 	case *ast.Program:
-		return evalStatements(n.Statements)
+		return e.evalProgram(n, env)
 	}
 	return nil
 }
 
-func evalStatements(stmts []ast.Statement) object.Object {
-	xlog.Debugf("eval statements: %#v\n", stmts)
+// a top-level return ends the program with its value
+func (e *Evaluator) evalProgram(prog *ast.Program, env *object.Environment) object.Object {
+	xlog.Debugf("eval statements: %#v\n", prog.Statements)
 	var result object.Object
-	for _, stmt := range stmts {
-		result = Eval(stmt)
+	for _, stmt := range prog.Statements {
+		result = e.Eval(stmt, env)
+		switch r := result.(type) {
+		case *object.ReturnValue:
+			return r.Value
+		case *object.Error:
+			return r
+		case *object.Break, *object.Continue:
+			return loopSignalError(r)
+		}
 	}
 	return result
 }
 
-func evalPrefixExpr(op string, right object.Object) object.Object {
+// the ReturnValue, Error, Break or Continue is passed up untouched so that
+// it also stops the enclosing blocks
+func (e *Evaluator) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object
+	for _, stmt := range block.Statements {
+		result = e.Eval(stmt, env)
+		if result != nil {
+			switch result.Type() {
+			case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+				return result
+			}
+		}
+	}
+	// a block always yields a value, even if it is empty or ends with a let
+	if result == nil {
+		return object.NULL
+	}
+	return result
+}
+
+func (e *Evaluator) evalIdentifier(ident *ast.Identifier, env *object.Environment) object.Object {
+	if val, ok := env.Get(ident.Value); ok {
+		return val
+	}
+	if b, ok := e.builtin(ident.Value); ok {
+		return b
+	}
+	return newError(codeUnknownIdentifier, "identifier not found: %s", ident.Value)
+}
+
+// evaluation stops at the first error, which is then returned alone
+func (e *Evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	result := make([]object.Object, 0, len(exps))
+	for _, exp := range exps {
+		evaluated := e.Eval(exp, env)
+		if interrupted(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+	return result
+}
+
+func (e *Evaluator) applyFunction(fn object.Object, args []object.Object) object.Object {
+	switch function := fn.(type) {
+	case *object.Function:
+		if len(args) != len(function.Parameters) {
+			return newError(codeArgumentCount, "wrong number of arguments: want=%d, got=%d",
+				len(function.Parameters), len(args))
+		}
+		env := extendFunctionEnv(function, args)
+		return unwrapReturnValue(e.Eval(function.Body, env))
+	case *object.Builtin:
+		return function.Fn(args...)
+	default:
+		return newError(codeNotFunction, "not a function: %s", fn.Type())
+	}
+}
+
+// parameters live in a new scope enclosed by the one the function was defined in,
+// that is what makes closures work
+func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+	env := object.NewEnclosedEnvironment(fn.Env)
+	for i, param := range fn.Parameters {
+		env.Define(param.Value, args[i])
+	}
+	return env
+}
+
+// a return must not leak out of the function it was executed in, neither
+// may a break or continue, the parser doesn't let them get there anyway
+func unwrapReturnValue(obj object.Object) object.Object {
+	switch o := obj.(type) {
+	case *object.ReturnValue:
+		return o.Value
+	case *object.Break, *object.Continue:
+		return loopSignalError(o)
+	}
+	return obj
+}
+
+func loopSignalError(signal object.Object) *object.Error {
+	return newError(codeOutsideLoop, "%s outside loop", signal.Inspect())
+}
+
+// loops are statements and yield no value. as with for, every run of the
+// body gets a new scope, so its lets don't outlive the iteration
+func (e *Evaluator) evalWhileStatement(ws *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		cond := e.Eval(ws.Condition, env)
+		if interrupted(cond) {
+			return cond
+		}
+		if !isTruthy(cond) {
+			return nil
+		}
+		result := e.Eval(ws.Body, object.NewEnclosedEnvironment(env))
+		if stop, ret := loopControl(result); stop {
+			return ret
+		}
+	}
+}
+
+// every iteration gets a new scope holding the loop variable, so closures
+// made in the body capture their own element
+func (e *Evaluator) evalForStatement(fs *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := e.Eval(fs.Iterable, env)
+	if interrupted(iterable) {
+		return iterable
+	}
+	elements, ok := iterate(iterable)
+	if !ok {
+		err := newError(codeNotIterable, "cannot iterate over %s", iterable.Type())
+		err.Span = fs.Iterable.Span()
+		return err
+	}
+	for _, el := range elements {
+		inner := object.NewEnclosedEnvironment(env)
+		inner.Define(fs.Variable.Value, el)
+		result := e.Eval(fs.Body, inner)
+		if stop, ret := loopControl(result); stop {
+			return ret
+		}
+	}
+	return nil
+}
+
+// loopControl tells whether the loop stops after a run of its body, and
+// what it then evaluates to: nothing for a break, the return value or
+// error otherwise
+func loopControl(result object.Object) (bool, object.Object) {
+	if result == nil {
+		return false, nil
+	}
+	switch result.Type() {
+	case object.BREAK_OBJ:
+		return true, nil
+	case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+		return true, result
+	}
+	return false, nil
+}
+
+// arrays yield their elements, strings their characters and hashes their
+// keys in insertion order
+func iterate(obj object.Object) ([]object.Object, bool) {
+	switch o := obj.(type) {
+	case *object.Array:
+		return o.Elements, true
+	case *object.String:
+		elements := make([]object.Object, 0, len(o.Value))
+		for _, r := range o.Value {
+			elements = append(elements, &object.String{Value: string(r)})
+		}
+		return elements, true
+	case *object.Hash:
+		pairs := o.Ordered()
+		elements := make([]object.Object, 0, len(pairs))
+		for _, pair := range pairs {
+			elements = append(elements, pair.Key)
+		}
+		return elements, true
+	}
+	return nil, false
+}
+
+// if yields the last value of the taken block, or NULL when no block is
+// taken. the block gets a scope of its own like any other block
+func (e *Evaluator) evalIfExpr(ie *ast.IfExpression, env *object.Environment) object.Object {
+	cond := e.Eval(ie.Condition, env)
+	if interrupted(cond) {
+		return cond
+	}
+	if isTruthy(cond) {
+		return e.Eval(ie.Consequence, object.NewEnclosedEnvironment(env))
+	} else if ie.Alternative != nil {
+		return e.Eval(ie.Alternative, object.NewEnclosedEnvironment(env))
+	}
+	return object.NULL
+}
+
+func evalIndexExpr(left, index object.Object) object.Object {
+	switch l := left.(type) {
+	case *object.Array:
+		return evalArrayIndexExpr(l, index)
+	case *object.Hash:
+		return evalHashIndexExpr(l, index)
+	default:
+		return newError(codeInvalidIndex, "index operator not supported: %s", left.Type())
+	}
+}
+
+// indexes count from 0, negative ones are out of range just like those past the end
+func evalArrayIndexExpr(array *object.Array, index object.Object) object.Object {
+	i, ok := index.(*object.IntegerObject)
+	if !ok {
+		return newError(codeInvalidIndex, "array index must be %s, got %s", object.INTEGER_OBJ, index.Type())
+	}
+	if i.Value < 0 || i.Value >= int64(len(array.Elements)) {
+		return newError(codeIndexOutOfRange, "index out of range: %d with length %d", i.Value, len(array.Elements))
+	}
+	return array.Elements[i.Value]
+}
+
+// a missing key yields NULL
+func evalHashIndexExpr(hash *object.Hash, index object.Object) object.Object {
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError(codeUnhashableKey, "unusable as hash key: %s", index.Type())
+	}
+	val, ok := hash.Get(key)
+	if !ok {
+		return object.NULL
+	}
+	return val
+}
+
+// keys and values are evaluated in source order, a repeated key keeps its
+// first position and takes the last value
+func (e *Evaluator) evalHashLiteral(hl *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+	for _, pair := range hl.Pairs {
+		key := e.Eval(pair.Key, env)
+		if interrupted(key) {
+			return key
+		}
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			err := newError(codeUnhashableKey, "unusable as hash key: %s", key.Type())
+			err.Span = pair.Key.Span()
+			return err
+		}
+		val := e.Eval(pair.Value, env)
+		if interrupted(val) {
+			return val
+		}
+		hash.Set(hashKey, val)
+	}
+	return hash
+}
+
+// an assignment evaluates to the value stored. compound forms read the
+// target before evaluating the value, and evaluate the container and
+// index of an index target only once
+func (e *Evaluator) evalAssignExpr(ae *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := ae.Target.(type) {
+	case *ast.Identifier:
+		var old object.Object
+		if ae.Operator != "=" {
+			var ok bool
+			if old, ok = env.Get(target.Value); !ok {
+				return undeclaredError(target)
+			}
+		}
+		val := e.evalAssignValue(ae, old, env)
+		if interrupted(val) {
+			return val
+		}
+		if !env.Set(target.Value, val) {
+			return undeclaredError(target)
+		}
+		return val
+	case *ast.IndexExpression:
+		left := e.Eval(target.Left, env)
+		if interrupted(left) {
+			return left
+		}
+		index := e.Eval(target.Index, env)
+		if interrupted(index) {
+			return index
+		}
+		var old object.Object
+		if ae.Operator != "=" {
+			old = evalIndexExpr(left, index)
+			if interrupted(old) {
+				return old
+			}
+		}
+		val := e.evalAssignValue(ae, old, env)
+		if interrupted(val) {
+			return val
+		}
+		return evalIndexAssign(left, index, val)
+	default:
+		return newError(codeUnknownOperator, "cannot assign to %s", ae.Target.String())
+	}
+}
+
+// evalAssignValue computes the value to store, combining it with old for
+// the compound operators
+func (e *Evaluator) evalAssignValue(ae *ast.AssignExpression, old object.Object, env *object.Environment) object.Object {
+	val := e.Eval(ae.Value, env)
+	if interrupted(val) || ae.Operator == "=" {
+		return val
+	}
+	return e.evalInfixExpr(strings.TrimSuffix(ae.Operator, "="), old, val)
+}
+
+func undeclaredError(ident *ast.Identifier) *object.Error {
+	err := newError(codeUndeclared, "assignment to undeclared identifier: %s", ident.Value)
+	err.Span = ident.Span()
+	return err
+}
+
+// arrays are changed in place, so every reference to them sees the new element
+func evalIndexAssign(left, index, val object.Object) object.Object {
+	switch l := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.IntegerObject)
+		if !ok {
+			return newError(codeInvalidIndex, "array index must be %s, got %s", object.INTEGER_OBJ, index.Type())
+		}
+		if i.Value < 0 || i.Value >= int64(len(l.Elements)) {
+			return newError(codeIndexOutOfRange, "index out of range: %d with length %d", i.Value, len(l.Elements))
+		}
+		l.Elements[i.Value] = val
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError(codeUnhashableKey, "unusable as hash key: %s", index.Type())
+		}
+		l.Set(key, val)
+	default:
+		return newError(codeInvalidIndex, "index assignment not supported: %s", left.Type())
+	}
+	return val
+}
+
+// the right operand is skipped when the left one decides the result,
+// both operators always produce a boolean
+func (e *Evaluator) evalLogicalExpr(le *ast.LogicalExpression, env *object.Environment) object.Object {
+	left := e.Eval(le.Left, env)
+	if interrupted(left) {
+		return left
+	}
+	switch le.Operator {
+	case "&&":
+		if !isTruthy(left) {
+			return object.FALSE
+		}
+	case "||":
+		if isTruthy(left) {
+			return object.TRUE
+		}
+	default:
+		return newError(codeUnknownOperator, "unknown operator: %s %s", left.Type(), le.Operator)
+	}
+	right := e.Eval(le.Right, env)
+	if interrupted(right) {
+		return right
+	}
+	return object.TrueOrFase(isTruthy(right))
+}
+
+// only false and null are falsy, evalPrefixBangExpr relies on it as well
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case object.FALSE, object.NULL:
+		return false
+	default:
+		return true
+	}
+}
+
+func (e *Evaluator) evalPrefixExpr(op string, right object.Object) object.Object {
 	switch op {
 	case "!":
 		return evalPrefixBangExpr(right)
 	case "-":
-		return evalPrefixSubExpr(right)
+		return e.evalPrefixSubExpr(right)
+	case "+":
+		return evalPrefixPlusExpr(right)
+	case "~":
+		return evalPrefixBitNotExpr(right)
 	}
-	return nil
+	return newError(codeUnknownOperator, "unknown operator: %s%s", op, right.Type())
 }
 
-func evalInfixExpr(op string, left, right object.Object) object.Object {
+func (e *Evaluator) evalInfixExpr(op string, left, right object.Object) object.Object {
 	switch op {
 	case "+":
-		return evalInfixPlusExpr(left, right)
+		return e.evalInfixPlusExpr(left, right)
 	case "-":
-		return evalInfixSubExpr(left, right)
+		return e.evalInfixSubExpr(left, right)
 	case "*":
-		return evalInfixMultiExpr(left, right)
+		return e.evalInfixMultiExpr(left, right)
 	case "/":
-		return evalInfixDivExpr(left, right)
+		return e.evalInfixDivExpr(left, right)
+	case "%":
+		return evalInfixModExpr(left, right)
+	case "&", "|", "^":
+		return evalInfixBitwiseExpr(op, left, right)
+	case "<<", ">>":
+		return e.evalInfixShiftExpr(op, left, right)
 	case ">":
 		return evalInfixGTExpr(left, right)
 	case "<":
 		return evalInfixLTExpr(left, right)
+	case ">=":
+		return evalInfixGTEExpr(left, right)
+	case "<=":
+		return evalInfixLTEExpr(left, right)
 	case "==":
 		return evalInfixEQTExpr(left, right)
 	case "!=":
 		return evalInfixNOTEQTExpr(left, right)
 	default:
-		return object.NULL
+		return newError(codeUnknownOperator, "unknown operator: %s %s %s", left.Type(), op, right.Type())
 	}
 }
 
-func evalInfixPlusExpr(left, right object.Object) object.Object {
+func (e *Evaluator) evalInfixPlusExpr(left, right object.Object) object.Object {
 	l, lOk := left.(*object.IntegerObject)
 	r, rOk := right.(*object.IntegerObject)
 	if lOk && rOk {
-		return &object.IntegerObject{Value: l.Value + r.Value}
+		v, ok := addInt64(l.Value, r.Value)
+		if !ok && e.CheckOverflow {
+			return newError(codeIntegerOverflow, "integer overflow: %d + %d", l.Value, r.Value)
+		}
+		return &object.IntegerObject{Value: v}
 	}
-	return object.NULL
+	if l, r, ok := floatOperands(left, right); ok {
+		return &object.Float{Value: l + r}
+	}
+	ls, lOk := left.(*object.String)
+	rs, rOk := right.(*object.String)
+	if lOk && rOk {
+		return &object.String{Value: ls.Value + rs.Value}
+	}
+	return infixOperandError("+", left, right)
 }
 
-func evalInfixSubExpr(left, right object.Object) object.Object {
+func (e *Evaluator) evalInfixSubExpr(left, right object.Object) object.Object {
 	l, lOk := left.(*object.IntegerObject)
 	r, rOk := right.(*object.IntegerObject)
 	if lOk && rOk {
-		return &object.IntegerObject{Value: l.Value - r.Value}
+		v, ok := subInt64(l.Value, r.Value)
+		if !ok && e.CheckOverflow {
+			return newError(codeIntegerOverflow, "integer overflow: %d - %d", l.Value, r.Value)
+		}
+		return &object.IntegerObject{Value: v}
 	}
-	return object.NULL
+	if l, r, ok := floatOperands(left, right); ok {
+		return &object.Float{Value: l - r}
+	}
+	return infixOperandError("-", left, right)
 }
 
-func evalInfixMultiExpr(left, right object.Object) object.Object {
+func (e *Evaluator) evalInfixMultiExpr(left, right object.Object) object.Object {
 	l, lOk := left.(*object.IntegerObject)
 	r, rOk := right.(*object.IntegerObject)
 	if lOk && rOk {
-		return &object.IntegerObject{Value: l.Value * r.Value}
+		v, ok := mulInt64(l.Value, r.Value)
+		if !ok && e.CheckOverflow {
+			return newError(codeIntegerOverflow, "integer overflow: %d * %d", l.Value, r.Value)
+		}
+		return &object.IntegerObject{Value: v}
 	}
-	return object.NULL
+	if l, r, ok := floatOperands(left, right); ok {
+		return &object.Float{Value: l * r}
+	}
+	return infixOperandError("*", left, right)
 }
 
-func evalInfixDivExpr(left, right object.Object) object.Object {
+func (e *Evaluator) evalInfixDivExpr(left, right object.Object) object.Object {
 	l, lOk := left.(*object.IntegerObject)
 	r, rOk := right.(*object.IntegerObject)
 	if lOk && rOk {
-		return &object.IntegerObject{Value: l.Value / r.Value}
+		if r.Value == 0 {
+			return newError(codeDivisionByZero, "division by zero: %d / 0", l.Value)
+		}
+		v, ok := divInt64(l.Value, r.Value)
+		if !ok && e.CheckOverflow {
+			return newError(codeIntegerOverflow, "integer overflow: %d / %d", l.Value, r.Value)
+		}
+		return &object.IntegerObject{Value: v}
 	}
-	return object.NULL
+	if l, r, ok := floatOperands(left, right); ok {
+		if r == 0 {
+			return newError(codeDivisionByZero, "division by zero: %s / 0", left.Inspect())
+		}
+		return &object.Float{Value: l / r}
+	}
+	return infixOperandError("/", left, right)
+}
+
+// the remainder takes the sign of the dividend like in Go and C, so that
+// a == (a / b) * b + a % b, floats follow math.Mod
+func evalInfixModExpr(left, right object.Object) object.Object {
+	l, lOk := left.(*object.IntegerObject)
+	r, rOk := right.(*object.IntegerObject)
+	if lOk && rOk {
+		if r.Value == 0 {
+			return newError(codeDivisionByZero, "modulo by zero: %d %% 0", l.Value)
+		}
+		return &object.IntegerObject{Value: l.Value % r.Value}
+	}
+	if l, r, ok := floatOperands(left, right); ok {
+		if r == 0 {
+			return newError(codeDivisionByZero, "modulo by zero: %s %% 0", left.Inspect())
+		}
+		return &object.Float{Value: math.Mod(l, r)}
+	}
+	return infixOperandError("%", left, right)
+}
+
+// bitwise operators only take integers, floats are not promoted
+func evalInfixBitwiseExpr(op string, left, right object.Object) object.Object {
+	l, lOk := left.(*object.IntegerObject)
+	r, rOk := right.(*object.IntegerObject)
+	if !lOk || !rOk {
+		return infixOperandError(op, left, right)
+	}
+	switch op {
+	case "&":
+		return &object.IntegerObject{Value: l.Value & r.Value}
+	case "|":
+		return &object.IntegerObject{Value: l.Value | r.Value}
+	default:
+		return &object.IntegerObject{Value: l.Value ^ r.Value}
+	}
+}
+
+// >> is arithmetic and keeps the sign, shifting by 64 or more gives 0
+// (or -1 for a negative value shifted right)
+func (e *Evaluator) evalInfixShiftExpr(op string, left, right object.Object) object.Object {
+	l, lOk := left.(*object.IntegerObject)
+	r, rOk := right.(*object.IntegerObject)
+	if !lOk || !rOk {
+		return infixOperandError(op, left, right)
+	}
+	if r.Value < 0 {
+		return newError(codeNegativeShift, "negative shift count: %d %s %d", l.Value, op, r.Value)
+	}
+	if op == ">>" {
+		return &object.IntegerObject{Value: l.Value >> r.Value}
+	}
+	v, ok := shlInt64(l.Value, r.Value)
+	if !ok && e.CheckOverflow {
+		return newError(codeIntegerOverflow, "integer overflow: %d << %d", l.Value, r.Value)
+	}
+	return &object.IntegerObject{Value: v}
 }
 
 func evalInfixGTExpr(left, right object.Object) object.Object {
 	l, lOk := left.(*object.IntegerObject)
 	r, rOk := right.(*object.IntegerObject)
 	if lOk && rOk {
-		return &object.BooleanObject{Value: l.Value > r.Value}
+		return object.TrueOrFase(l.Value > r.Value)
 	}
-	return object.NULL
+	if l, r, ok := floatOperands(left, right); ok {
+		return object.TrueOrFase(l > r)
+	}
+	return infixOperandError(">", left, right)
 }
 
 func evalInfixLTExpr(left, right object.Object) object.Object {
 	l, lOk := left.(*object.IntegerObject)
 	r, rOk := right.(*object.IntegerObject)
 	if lOk && rOk {
-		return &object.BooleanObject{Value: l.Value < r.Value}
+		return object.TrueOrFase(l.Value < r.Value)
 	}
-	return object.NULL
+	if l, r, ok := floatOperands(left, right); ok {
+		return object.TrueOrFase(l < r)
+	}
+	return infixOperandError("<", left, right)
 }
+
+func evalInfixGTEExpr(left, right object.Object) object.Object {
+	l, lOk := left.(*object.IntegerObject)
+	r, rOk := right.(*object.IntegerObject)
+	if lOk && rOk {
+		return object.TrueOrFase(l.Value >= r.Value)
+	}
+	if l, r, ok := floatOperands(left, right); ok {
+		return object.TrueOrFase(l >= r)
+	}
+	return infixOperandError(">=", left, right)
+}
+
+func evalInfixLTEExpr(left, right object.Object) object.Object {
+	l, lOk := left.(*object.IntegerObject)
+	r, rOk := right.(*object.IntegerObject)
+	if lOk && rOk {
+		return object.TrueOrFase(l.Value <= r.Value)
+	}
+	if l, r, ok := floatOperands(left, right); ok {
+		return object.TrueOrFase(l <= r)
+	}
+	return infixOperandError("<=", left, right)
+}
+
+// values of other types are compared by identity, which is right for the
+// boolean and null singletons
 func evalInfixEQTExpr(left, right object.Object) object.Object {
 	l, lOk := left.(*object.IntegerObject)
 	r, rOk := right.(*object.IntegerObject)
 	if lOk && rOk {
-		return &object.BooleanObject{Value: l.Value == r.Value}
+		return object.TrueOrFase(l.Value == r.Value)
 	}
-	return object.NULL
+	if l, r, ok := floatOperands(left, right); ok {
+		return object.TrueOrFase(l == r)
+	}
+	ls, lOk := left.(*object.String)
+	rs, rOk := right.(*object.String)
+	if lOk && rOk {
+		return object.TrueOrFase(ls.Value == rs.Value)
+	}
+	if left.Type() != right.Type() {
+		return infixOperandError("==", left, right)
+	}
+	return object.TrueOrFase(left == right)
 }
+
 func evalInfixNOTEQTExpr(left, right object.Object) object.Object {
 	l, lOk := left.(*object.IntegerObject)
 	r, rOk := right.(*object.IntegerObject)
 	if lOk && rOk {
-		return &object.BooleanObject{Value: l.Value != r.Value}
+		return object.TrueOrFase(l.Value != r.Value)
 	}
-	return object.NULL
+	if l, r, ok := floatOperands(left, right); ok {
+		return object.TrueOrFase(l != r)
+	}
+	ls, lOk := left.(*object.String)
+	rs, rOk := right.(*object.String)
+	if lOk && rOk {
+		return object.TrueOrFase(ls.Value != rs.Value)
+	}
+	if left.Type() != right.Type() {
+		return infixOperandError("!=", left, right)
+	}
+	return object.TrueOrFase(left != right)
 }
 
 func evalPrefixBangExpr(obj object.Object) object.Object {
-	switch obj {
-	case object.TRUE:
-		return object.FALSE
-	case object.FALSE:
-		return object.TRUE
-	case object.NULL:
-		return object.TRUE
+	return object.TrueOrFase(!isTruthy(obj))
+}
+
+func (e *Evaluator) evalPrefixSubExpr(obj object.Object) object.Object {
+	switch o := obj.(type) {
+	case *object.IntegerObject:
+		v, ok := negInt64(o.Value)
+		if !ok && e.CheckOverflow {
+			return newError(codeIntegerOverflow, "integer overflow: -(%d)", o.Value)
+		}
+		return &object.IntegerObject{Value: v}
+	case *object.Float:
+		return &object.Float{Value: -o.Value}
+	default:
+		return newError(codeUnknownOperator, "unknown operator: -%s", obj.Type())
+	}
+}
+
+// unary plus only accepts numbers and returns them unchanged
+func evalPrefixPlusExpr(obj object.Object) object.Object {
+	switch obj.(type) {
+	case *object.IntegerObject, *object.Float:
+		return obj
 	default:
-		return object.FALSE
+		return newError(codeUnknownOperator, "unknown operator: +%s", obj.Type())
 	}
 }
 
-func evalPrefixSubExpr(obj object.Object) object.Object {
+func evalPrefixBitNotExpr(obj object.Object) object.Object {
+	o, ok := obj.(*object.IntegerObject)
+	if !ok {
+		return newError(codeUnknownOperator, "unknown operator: ~%s", obj.Type())
+	}
+	return &object.IntegerObject{Value: ^o.Value}
+}
+
+// floatOperands promotes a pair of numbers to floats, it is meant to be
+// tried after the integer-only case, so in practice one of them is a float
+func floatOperands(left, right object.Object) (float64, float64, bool) {
+	l, lOk := toFloat(left)
+	r, rOk := toFloat(right)
+	return l, r, lOk && rOk
+}
+
+func toFloat(obj object.Object) (float64, bool) {
 	switch o := obj.(type) {
 	case *object.IntegerObject:
-		return &object.IntegerObject{Value: -o.Value}
-	default:
-		return object.NULL
+		return float64(o.Value), true
+	case *object.Float:
+		return o.Value, true
+	}
+	return 0, false
+}
+
+// operands of different types are a mismatch, otherwise the type just
+// does not support op
+func infixOperandError(op string, left, right object.Object) *object.Error {
+	if left.Type() != right.Type() {
+		return newError(codeTypeMismatch, "type mismatch: %s %s %s", left.Type(), op, right.Type())
+	}
+	return newError(codeUnknownOperator, "unknown operator: %s %s %s", left.Type(), op, right.Type())
+}
+
+func newError(code, format string, a ...any) *object.Error {
+	return &object.Error{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+func isError(obj object.Object) bool {
+	return obj != nil && obj.Type() == object.ERROR_OBJ
+}
+
+// an error, a return, a break or a continue stops the evaluation of
+// whatever uses the value, it is passed up until a loop, a function or the
+// program takes it
+func interrupted(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+	switch obj.Type() {
+	case object.ERROR_OBJ, object.RETURN_VALUE_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+		return true
 	}
+	return false
 }
@@ -0,0 +1,1408 @@
+package evaluator
+
+import (
+	"bytes"
+	"interrupter/ast"
+	"interrupter/lexer"
+	"interrupter/object"
+	"interrupter/parser"
+	"interrupter/token"
+	"math"
+	"strings"
+	"testing"
+)
+
+func testEval(input string) object.Object {
+	return testEvalWith(New(), input)
+}
+
+func testEvalWith(e *Evaluator, input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+	return e.Eval(program, env)
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.IntegerObject)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d",
+			result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	result, ok := obj.(*object.BooleanObject)
+	if !ok {
+		t.Errorf("object is not Boolean. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%t, want=%t",
+			result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func TestEvalIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"50 / 2 * 2 + 10", 60},
+		{"3 * (3 * 3) + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"!true", false},
+		{"!!5", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"let a = 5; a * 2", 10},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestEnvironmentScopes(t *testing.T) {
+	outer := object.NewEnvironment()
+	outer.Define("a", &object.IntegerObject{Value: 1})
+	inner := object.NewEnclosedEnvironment(outer)
+	inner.Define("b", &object.IntegerObject{Value: 2})
+
+	if v, ok := inner.Get("a"); !ok || !testIntegerObject(t, v, 1) {
+		t.Fatalf("inner scope should see outer binding a")
+	}
+	if _, ok := outer.Get("b"); ok {
+		t.Fatalf("outer scope should not see inner binding b")
+	}
+
+	if !inner.Set("a", &object.IntegerObject{Value: 3}) {
+		t.Fatalf("Set should find a in outer scope")
+	}
+	if v, _ := outer.Get("a"); !testIntegerObject(t, v, 3) {
+		t.Fatalf("Set should rebind a in the declaring scope")
+	}
+	if inner.Set("c", object.NULL) {
+		t.Fatalf("Set should fail on undeclared name")
+	}
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+		{"if (1 < 2) { 5; 10 } else { 20 }", 10},
+		{"let a = if (!false) { 1 } else { 2 }; a", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	if obj != object.NULL {
+		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
+		return false
+	}
+	return true
+}
+
+func TestFunctionObject(t *testing.T) {
+	input := "fn(x) { x + 2; };"
+
+	evaluated := testEval(input)
+	fn, ok := evaluated.(*object.Function)
+	if !ok {
+		t.Fatalf("object is not Function. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(fn.Parameters) != 1 {
+		t.Fatalf("function has wrong parameters. Parameters=%+v",
+			fn.Parameters)
+	}
+
+	if fn.Parameters[0].String() != "x" {
+		t.Fatalf("parameter is not 'x'. got=%q", fn.Parameters[0])
+	}
+
+	expectedBody := "(x + 2)"
+
+	if fn.Body.String() != expectedBody {
+		t.Fatalf("body is not %q. got=%q", expectedBody, fn.Body.String())
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(a, b) { a + b }; add(1, 2)", 3},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+		{"let apply = fn(f, x) { f(x) }; apply(fn(x) { x * 3 }, 4)", 12},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	input := `
+let newAdder = fn(x) {
+	fn(y) { x + y };
+};
+let addTwo = newAdder(2);
+let x = 100;
+addTwo(2);`
+
+	testIntegerObject(t, testEval(input), 4)
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{"if (10 > 1) { return 10; }", 10},
+		{
+			`
+if (10 > 1) {
+	if (10 > 1) {
+		return 10;
+	}
+
+	return 1;
+}
+`,
+			10,
+		},
+		{
+			`
+let f = fn(x) {
+	return x;
+	x + 10;
+};
+f(10);`,
+			10,
+		},
+		{
+			`
+let f = fn(x) {
+	let result = x + 10;
+	return result;
+	return 10;
+};
+f(10);`,
+			20,
+		},
+		{"let f = fn() { return 1; }; f() + f() + 3", 5},
+		// a return inside an if used as a value still leaves the function
+		{"let f = fn() { let x = if (true) { return 5 }; 10 }; f()", 5},
+		{"let f = fn() { 1 + if (true) { return 5 }; 10 }; f()", 5},
+		{"let f = fn() { [if (true) { return 5 }]; 10 }; f()", 5},
+		{"let f = fn() { puts(if (true) { return 5 }); 10 }; f()", 5},
+		{"let f = fn() { {\"a\": if (true) { return 5 }}; 10 }; f()", 5},
+		{"let f = fn() { let x = 1; x = if (true) { return 5 }; 10 }; f()", 5},
+		{"let f = fn() { return if (true) { return 5 } else { 1 } }; f()", 5},
+		{"let x = if (true) { return 5 }; 10", 5},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestBareReturn(t *testing.T) {
+	testNullObject(t, testEval("let f = fn() { return; 1 }; f()"))
+	testNullObject(t, testEval("return"))
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
+		{"true + 1", "type mismatch: BOOLEAN + INTEGER"},
+		{"1 == true", "type mismatch: INTEGER == BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"true < false;", "unknown operator: BOOLEAN < BOOLEAN"},
+		{"5; true + false; 5", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (10 > 1) { true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{
+			`
+if (10 > 1) {
+	if (10 > 1) {
+		return true + false;
+	}
+
+	return 1;
+}
+`,
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{"x", "identifier not found: x"},
+		{"let a = x; 1", "identifier not found: x"},
+		{"-(1 + x)", "identifier not found: x"},
+		{"if (x) { 1 }", "identifier not found: x"},
+		{"let f = fn(a) { a }; f(1, x)", "identifier not found: x"},
+		{"let f = fn(a) { a }; f(1, 2)", "wrong number of arguments: want=1, got=2"},
+		{"1(2)", "not a function: INTEGER"},
+		{"let f = fn() { return true + 1; 2 }; f(); 3", "type mismatch: BOOLEAN + INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestBooleanEquality(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true == true", true},
+		{"true != false", true},
+		{"false == false", true},
+		{"(1 < 2) == true", true},
+		{"(1 > 2) != false", false},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestDivisionByZero(t *testing.T) {
+	tests := []string{"1 / 0", "let a = 0; 10 / a", "let f = fn(x) { 1 / x }; f(0)"}
+
+	for _, input := range tests {
+		for _, e := range []*Evaluator{{}, {CheckOverflow: true}} {
+			evaluated := testEvalWith(e, input)
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T(%+v)", input, evaluated, evaluated)
+				continue
+			}
+			if !strings.HasPrefix(errObj.Message, "division by zero") {
+				t.Errorf("wrong error message for %q. got=%q", input, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestIntegerOverflow(t *testing.T) {
+	const (
+		max = "9223372036854775807"
+		min = "(-9223372036854775807 - 1)"
+	)
+	tests := []struct {
+		input    string
+		wrapped  int64
+		overflow bool
+	}{
+		{max + " + 1", math.MinInt64, true},
+		{max + " + 0", math.MaxInt64, false},
+		{min + " - 1", math.MaxInt64, true},
+		{min + " + 1", math.MinInt64 + 1, false},
+		{"-" + max + " - 1", math.MinInt64, false},
+		{max + " * 2", -2, true},
+		{min + " * -1", math.MinInt64, true},
+		{"-1 * " + min, math.MinInt64, true},
+		{min + " * 1", math.MinInt64, false},
+		{"4611686018427387904 * 2", math.MinInt64, true},
+		{"4611686018427387903 * 2", math.MaxInt64 - 1, false},
+		{"-4611686018427387904 * 2", math.MinInt64, false},
+		{min + " / -1", math.MinInt64, true},
+		{min + " / 1", math.MinInt64, false},
+		{"-" + min, math.MinInt64, true},
+		{"-" + max, -math.MaxInt64, false},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.wrapped)
+
+		evaluated := testEvalWith(&Evaluator{CheckOverflow: true}, tt.input)
+		if !tt.overflow {
+			testIntegerObject(t, evaluated, tt.wrapped)
+			continue
+		}
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if !strings.HasPrefix(errObj.Message, "integer overflow") {
+			t.Errorf("wrong error message for %q. got=%q", tt.input, errObj.Message)
+		}
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + true", "ERROR: 1:1: type mismatch: INTEGER + BOOLEAN"},
+		{"let a = 1;\nlet b = -true", "ERROR: 2:9: unknown operator: -BOOLEAN"},
+		{"1 + (2 * x)", "ERROR: 1:10: identifier not found: x"},
+		{"let f = fn(x) {\n  x / 0\n};\nf(1)", "ERROR: 2:3: division by zero: 1 / 0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Inspect() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errObj.Inspect())
+		}
+	}
+}
+
+func TestStringLiteral(t *testing.T) {
+	evaluated := testEval(`"Hello World!"`)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+	if str.Value != "Hello World!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestStringConcatenation(t *testing.T) {
+	evaluated := testEval(`let greet = fn(name) { "Hello" + ", " + name + "!" }; greet("fork")`)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got=%T (%+v)", evaluated, evaluated)
+	}
+	if str.Value != "Hello, fork!" {
+		t.Errorf("String has wrong value. got=%q", str.Value)
+	}
+}
+
+func TestStringComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"a" + "b" == "ab"`, true},
+		{`"" != ""`, false},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`"a" - "b"`, "unknown operator: STRING - STRING"},
+		{`"a" + 1`, "type mismatch: STRING + INTEGER"},
+		{`"a" == 1`, "type mismatch: STRING == INTEGER"},
+		{`-"a"`, "unknown operator: -STRING"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d",
+			len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+
+	if result.Inspect() != "[1, 4, 6]" {
+		t.Errorf("wrong Inspect. got=%q", result.Inspect())
+	}
+}
+
+func TestArrayInspect(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[]", "[]"},
+		{`[1, "a, b", true, [2]]`, `[1, "a, b", true, [2]]`},
+	}
+
+	for _, tt := range tests {
+		if actual := testEval(tt.input).Inspect(); actual != tt.expected {
+			t.Errorf("wrong Inspect. expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[2];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]", 2},
+		{"[fn(x) { x * 2 }][0](4)", 8},
+		{"[1, 2, 3][3]", "index out of range: 3 with length 3"},
+		{"[1, 2, 3][-1]", "index out of range: -1 with length 3"},
+		{"[][0]", "index out of range: 0 with length 0"},
+		{`[1][true]`, "array index must be INTEGER, got BOOLEAN"},
+		{"1[0]", "index operator not supported: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():    1,
+		(&object.String{Value: "two"}).HashKey():    2,
+		(&object.String{Value: "three"}).HashKey():  3,
+		(&object.IntegerObject{Value: 4}).HashKey(): 4,
+		object.TRUE.HashKey():                       5,
+		object.FALSE.HashKey():                      6,
+	}
+
+	if result.Len() != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", result.Len())
+	}
+
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+
+	if result.Inspect() != `{"one": 1, "two": 2, "three": 3, 4: 4, true: 5, false: 6}` {
+		t.Errorf("Inspect doesn't keep insertion order. got=%s", result.Inspect())
+	}
+}
+
+func TestHashKeys(t *testing.T) {
+	hello1 := &object.String{Value: "Hello World"}
+	hello2 := &object.String{Value: "Hello World"}
+	diff := &object.String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+	if hello1.HashKey() == diff.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+	if (&object.IntegerObject{Value: 1}).HashKey() == object.TRUE.HashKey() {
+		t.Errorf("1 and true have the same hash key")
+	}
+	// the key holds the whole string, not a hash of it that may collide
+	if key := diff.HashKey(); key.Str != diff.Value {
+		t.Errorf("string key doesn't hold the string. got=%q", key.Str)
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		{`{"a": 1, "a": 2}["a"]`, 2},
+		{`{"name": "x", 1: true}[1 == 1]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"name": "Monkey"}[fn(x) { x }];`, "ERROR: 1:1: unusable as hash key: FUNCTION"},
+		{`{[1]: 2}`, "ERROR: 1:2: unusable as hash key: ARRAY"},
+		{`{"a": x}`, "ERROR: 1:7: identifier not found: x"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+			continue
+		}
+		if errObj.Inspect() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errObj.Inspect())
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len("中文")`, 2},
+		{`len([1, 2, 3])`, 3},
+		{`len({"a": 1})`, 1},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments to `len`: want=1, got=2"},
+		{`first([1, 2, 3])`, 1},
+		{`first([])`, nil},
+		{`first(1)`, "argument to `first` must be ARRAY, got INTEGER"},
+		{`last([1, 2, 3])`, 3},
+		{`last([])`, nil},
+		{`last(1)`, "argument to `last` must be ARRAY, got INTEGER"},
+		{`rest([1, 2, 3])`, []int{2, 3}},
+		{`rest([1])`, []int{}},
+		{`rest([])`, nil},
+		{`push([], 1)`, []int{1}},
+		{`let a = [1]; push(a, 2); a`, []int{1}},
+		{`push(1, 1)`, "argument to `push` must be ARRAY, got INTEGER"},
+		{`push([1])`, "wrong number of arguments to `push`: want=2, got=1"},
+		{`let len = fn(x) { 42 }; len([])`, 42},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		case []int:
+			array, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("obj not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(array.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(array.Elements))
+				continue
+			}
+			for i, expectedElem := range expected {
+				testIntegerObject(t, array.Elements[i], int64(expectedElem))
+			}
+		}
+	}
+}
+
+func TestBuiltinType(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`type(1)`, "INTEGER"},
+		{`type("a")`, "STRING"},
+		{`type([])`, "ARRAY"},
+		{`type({})`, "HASH"},
+		{`type(fn() {})`, "FUNCTION"},
+		{`type(len)`, "BUILTIN"},
+		{`type(if (false) { 1 })`, "NULL"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok || str.Value != tt.expected {
+			t.Errorf("type() wrong for %q. want=%q, got=%+v", tt.input, tt.expected, evaluated)
+		}
+	}
+}
+
+func TestPutsWritesToOut(t *testing.T) {
+	var out bytes.Buffer
+	e := New()
+	e.Out = &out
+
+	evaluated := testEvalWith(e, `puts("hello", 1 + 2, [1, "a"]); puts()`)
+	testNullObject(t, evaluated)
+
+	expected := "hello\n3\n[1, \"a\"]\n"
+	if out.String() != expected {
+		t.Errorf("puts wrote wrong output. want=%q, got=%q", expected, out.String())
+	}
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g",
+			result.Value, expected)
+		return false
+	}
+	return true
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.5", 3.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 + 1", 1.5},
+		{"5 - 0.5", 4.5},
+		{"2 * 0.25", 0.5},
+		{"1 / 4.0", 0.25},
+		{"7.0 / 2", 3.5},
+		{"let ratio = fn(a, b) { a / (b * 1.0) }; ratio(1, 8)", 0.125},
+		{"1e3 + 1", 1001},
+	}
+
+	for _, tt := range tests {
+		testFloatObject(t, testEval(tt.input), tt.expected)
+	}
+
+	// integers stay integers
+	testIntegerObject(t, testEval("7 / 2"), 3)
+}
+
+func TestFloatComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"0.1 < 0.2", true},
+		{"1 < 1.5", true},
+		{"1.5 > 2", false},
+		{"1 == 1.0", true},
+		{"1.0 != 1", false},
+		{"0.1 + 0.2 == 0.3", false},
+		{"!0.0", false},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFloatErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1.5 / 0", "division by zero: 1.5 / 0"},
+		{"1 / 0.0", "division by zero: 1 / 0"},
+		{"1.5 + true", "type mismatch: FLOAT + BOOLEAN"},
+		{`"a" + 1.5`, "type mismatch: STRING + FLOAT"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestFloatInspectRoundTrip(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{1, "1.0"},
+		{-0.5, "-0.5"},
+		{3.14, "3.14"},
+		{1e21, "1e+21"},
+		{1e-9, "1e-09"},
+		{math.Nextafter(0.3, 1), "0.30000000000000004"},
+		{math.MaxFloat64, "1.7976931348623157e+308"},
+	}
+
+	for _, tt := range tests {
+		f := &object.Float{Value: tt.value}
+		if f.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect. want=%q, got=%q", tt.expected, f.Inspect())
+		}
+		if tt.value < 0 {
+			continue
+		}
+		testFloatObject(t, testEval(f.Inspect()), tt.value)
+	}
+}
+
+func TestLogicalExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false && true", false},
+		{"true || false", true},
+		{"false || false", false},
+		{"false || true", true},
+		{"1 && 2", true},
+		{"1 && if (false) { 1 }", false},
+		{"0 || false", true},
+		{"if (false) { 1 } || 0", true},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{"false || true && false", false},
+		{`"" && []`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestLogicalShortCircuit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"false && undefined", false},
+		{"true || undefined", true},
+		{"false && 1 / 0", false},
+		{"true || missing()", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+
+	var out bytes.Buffer
+	e := New()
+	e.Out = &out
+	testEvalWith(e, `false && puts("left"); true || puts("right"); true && puts("ran")`)
+	if out.String() != "ran\n" {
+		t.Errorf("right operand evaluated when it should not be. got=%q", out.String())
+	}
+
+	evaluated := testEval("true && undefined")
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if errObj.Message != "identifier not found: undefined" {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+}
+
+func TestModuloExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"7 % -3", 1},
+		{"-7 % -3", -1},
+		{"6 % 3", 0},
+		{"1 + 7 % 4 * 2", 7},
+		{"(-9223372036854775807 - 1) % -1", 0},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	floats := []struct {
+		input    string
+		expected float64
+	}{
+		{"7.5 % 2", 1.5},
+		{"-7.5 % 2", -1.5},
+		{"7 % 2.5", 2},
+	}
+
+	for _, tt := range floats {
+		testFloatObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestComparisonOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"1.5 >= 1", true},
+		{"1 <= 0.5", false},
+		{"1 + 1 >= 2 == true", true},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestUnaryPlus(t *testing.T) {
+	testIntegerObject(t, testEval("+5"), 5)
+	testIntegerObject(t, testEval("+-5"), -5)
+	testIntegerObject(t, testEval("-+5"), -5)
+	testIntegerObject(t, testEval("1 - +2"), -1)
+	testFloatObject(t, testEval("+2.5"), 2.5)
+}
+
+func TestArithmeticOperatorErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 % 0", "modulo by zero: 5 % 0"},
+		{"5.5 % 0", "modulo by zero: 5.5 % 0"},
+		{"5 % 0.0", "modulo by zero: 5 % 0"},
+		{`"a" % 2`, "type mismatch: STRING % INTEGER"},
+		{"true % false", "unknown operator: BOOLEAN % BOOLEAN"},
+		{`"a" <= "b"`, "unknown operator: STRING <= STRING"},
+		{"1 >= true", "type mismatch: INTEGER >= BOOLEAN"},
+		{`+"a"`, "unknown operator: +STRING"},
+		{"+true", "unknown operator: +BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestBitwiseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"12 & 10", 8},
+		{"12 | 10", 14},
+		{"12 ^ 10", 6},
+		{"~0", -1},
+		{"~5", -6},
+		{"~-1", 0},
+		{"-8 & 0xF", 8},
+		{"0b1010 | 0b0101", 15},
+		{"0xFF ^ 0xFF", 0},
+		{"1 << 0", 1},
+		{"1 << 10", 1024},
+		{"3 << 62", -4611686018427387904},
+		{"1 << 64", 0},
+		{"1024 >> 3", 128},
+		{"-16 >> 2", -4},
+		{"-1 >> 63", -1},
+		{"-1 >> 100", -1},
+		{"5 >> 64", 0},
+		{"1 | 2 ^ 3 & 4", 3},
+		{"1 << 2 + 1", 8},
+		{"~1 & 7", 6},
+		{"let flags = 0b101; flags & 0b100", 4},
+		{"let flags = 0; let mask = flags | 1 << 3; mask", 8},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	booleans := []struct {
+		input    string
+		expected bool
+	}{
+		{"let flags = 0b110; flags & 0b100 == 0b100", true},
+		{"let flags = 0b010; flags & 0b100 == 0b100", false},
+		{"1 | 2 > 2", true},
+		{"1 << 3 >= 8 && 8 >> 3 <= 1", true},
+	}
+
+	for _, tt := range booleans {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestBitwiseErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 << -1", "negative shift count: 1 << -1"},
+		{"8 >> -2", "negative shift count: 8 >> -2"},
+		{"1.5 & 1", "type mismatch: FLOAT & INTEGER"},
+		{"1 | 2.0", "type mismatch: INTEGER | FLOAT"},
+		{"1.0 ^ 2.0", "unknown operator: FLOAT ^ FLOAT"},
+		{"true & false", "unknown operator: BOOLEAN & BOOLEAN"},
+		{`"a" << 1`, "type mismatch: STRING << INTEGER"},
+		{"1 >> 0.5", "type mismatch: INTEGER >> FLOAT"},
+		{"~1.5", "unknown operator: ~FLOAT"},
+		{"~true", "unknown operator: ~BOOLEAN"},
+		{`~"a"`, "unknown operator: ~STRING"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q", tt.input)
+			continue
+		}
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestShiftOverflow(t *testing.T) {
+	tests := []struct {
+		input    string
+		overflow bool
+	}{
+		{"1 << 62", false},
+		{"1 << 63", true},
+		{"-1 << 63", false},
+		{"3 << 62", true},
+		{"0 << 100", false},
+		{"1 << 64", true},
+		{"-4 << 61", false},
+	}
+
+	e := &Evaluator{CheckOverflow: true}
+	for _, tt := range tests {
+		evaluated := testEvalWith(e, tt.input)
+		errObj, isErr := evaluated.(*object.Error)
+		if isErr != tt.overflow {
+			t.Errorf("%q: overflow=%t, got %s", tt.input, tt.overflow, evaluated.Inspect())
+			continue
+		}
+		if isErr && !strings.HasPrefix(errObj.Message, "integer overflow") {
+			t.Errorf("wrong error message for %q. got=%q", tt.input, errObj.Message)
+		}
+	}
+}
+
+func TestForLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`for (x in [1, 2, 3]) { puts(x) }`, "1\n2\n3\n"},
+		{`for (c in "héy") { puts(c) }`, "h\né\ny\n"},
+		{`for (k in {"b": 1, "a": 2}) { puts(k) }`, "b\na\n"},
+		{`for (x in []) { puts(x) }`, ""},
+		{`for (x in [1, 2, 3, 4]) { if (x == 2) { continue } if (x == 4) { break } puts(x) }`, "1\n3\n"},
+		{`for (x in [1, 2]) { for (y in [3, 4]) { if (y == 4) { break } puts(x * y) } }`, "3\n6\n"},
+		{`for (x in [1, 2]) { for (y in [3, 4]) { continue; puts(y) } puts(x) }`, "1\n2\n"},
+		{`let x = 10; for (x in [1]) { puts(x) } puts(x)`, "1\n10\n"},
+		{`while (false) { puts(1) } puts(2)`, "2\n"},
+		{`while (true) { puts(1); break; puts(2) }`, "1\n"},
+		{`while (true) { for (x in [1, 2]) { break } puts("out"); break }`, "out\n"},
+		// an if used as a value passes break and continue on to the loop
+		{`while (true) { let x = if (true) { break }; puts(x) } puts("done")`, "done\n"},
+		{`for (x in [1, 2]) { puts([if (x == 1) { continue }, x]) }`, "[null, 2]\n"},
+		{`for (x in [1, 2]) { puts(1 + if (x == 1) { continue } else { x }) }`, "3\n"},
+		{`for (x in [1, 2]) { let h = {"k": if (x == 2) { break }}; puts(x) }`, "1\n"},
+		{`let y = 0; while (true) { y = if (y < 2) { y + 1 } else { break } } puts(y)`, "2\n"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		e := New()
+		e.Out = &out
+		evaluated := testEvalWith(e, tt.input)
+		if isError(evaluated) {
+			t.Errorf("%q: unexpected error %s", tt.input, evaluated.Inspect())
+			continue
+		}
+		if out.String() != tt.expected {
+			t.Errorf("%q: wrong output. want=%q, got=%q", tt.input, tt.expected, out.String())
+		}
+	}
+}
+
+func TestLoopValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let f = fn() { while (true) { return 5 } }; f()", 5},
+		{"let f = fn(a) { for (x in a) { if (x > 2) { return x } } 0 }; f([1, 3, 4])", 3},
+		{"let f = fn(a) { for (x in a) { if (x > 2) { return x } } 0 }; f([1, 2])", 0},
+		{"let g = fn(a) { for (x in a) { return fn() { x } } }; g([7, 8])()", 7},
+		{"for (x in [1]) { return 9 } 1", 9},
+		{"while (true) { break } 4", 4},
+		// both loops give their body a scope of its own
+		{"let x = 1; while (true) { let x = 2; break } x", 1},
+		{"let x = 1; for (y in [0]) { let x = 2 } x", 1},
+		{"let n = 0; let i = 0; while (i < 3) { let d = i; i += 1; n += d } n", 3},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	// a loop is a statement and has no value of its own
+	if evaluated := testEval("for (x in [1]) { x }"); evaluated != nil {
+		t.Errorf("loop has a value. got=%s", evaluated.Inspect())
+	}
+	testNullObject(t, testEval("let f = fn() { while (false) {} }; f()"))
+}
+
+func TestIfScope(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let x = 1; if (true) { let x = 2 }; x", 1},
+		{"let x = 1; if (false) { 0 } else { let x = 3 }; x", 1},
+		{"let x = 1; if (true) { let x = 2; x }", 2},
+		{"let x = 1; if (true) { x = 2 }; x", 2},
+		{"let f = fn() { let x = 1; if (true) { let x = 5 }; x }; f()", 1},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	errObj, ok := testEval("if (true) { let y = 1 }; y").(*object.Error)
+	if !ok || errObj.Message != "identifier not found: y" {
+		t.Errorf("let leaked out of the if. got=%v", errObj)
+	}
+}
+
+func TestLoopErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"for (x in 5) { x }", "1:11: cannot iterate over INTEGER"},
+		{"for (x in true) { x }", "1:11: cannot iterate over BOOLEAN"},
+		{"for (x in [1, 2]) { x + true }", "1:21: type mismatch: INTEGER + BOOLEAN"},
+		{"while (missing) { 1 }", "1:8: identifier not found: missing"},
+		{"while (true) { 1 / 0 }", "1:16: division by zero: 1 / 0"},
+		{"while (true) { let y = 1; break } y", "1:35: identifier not found: y"},
+		{"for (x in [1]) { let y = 1 } y", "1:30: identifier not found: y"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q", tt.input)
+			continue
+		}
+		msg := errObj.Span.Start.String() + ": " + errObj.Message
+		if msg != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, msg)
+		}
+	}
+}
+
+func TestLoopSignalsStopAtFunctions(t *testing.T) {
+	// the parser rejects these, so build the trees by hand
+	brk := &ast.BreakStatement{Token: token.Token{Type: token.BREAK, Literal: "break"}}
+	cont := &ast.ContinueStatement{Token: token.Token{Type: token.CONTINUE, Literal: "continue"}}
+
+	program := &ast.Program{Statements: []ast.Statement{brk}}
+	testLoopSignalError(t, New().Eval(program, object.NewEnvironment()), "break outside loop")
+
+	fn := &object.Function{
+		Body: &ast.BlockStatement{Statements: []ast.Statement{cont}},
+		Env:  object.NewEnvironment(),
+	}
+	testLoopSignalError(t, New().applyFunction(fn, nil), "continue outside loop")
+}
+
+func testLoopSignalError(t *testing.T, obj object.Object, expected string) {
+	errObj, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("no error object returned. got=%T(%+v)", obj, obj)
+		return
+	}
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = 5", 5},
+		{"let x = 1; x += 2; x", 3},
+		{"let x = 5; x -= 2; x", 3},
+		{"let x = 5; x *= 2; x", 10},
+		{"let x = 9; x /= 2; x", 4},
+		{"let a = 1; let b = 2; a = b = 7; a + b", 14},
+		{"let x = 1; let f = fn() { x = 10 }; f(); x", 10},
+		{"let x = 1; let f = fn() { let x = 2; x = 3; x }; f() + x", 4},
+		{"let x = 1; let f = fn() { let g = fn() { x += 1 }; g(); g() }; f(); x", 3},
+		{"let i = 0; let sum = 0; while (i < 5) { i += 1; sum += i } sum", 15},
+		{"let n = 0; for (x in [1, 2, 3]) { n = n * 10 + x } n", 123},
+		{"let a = [1, 2, 3]; a[1] = 20; a[1]", 20},
+		{"let a = [1, 2, 3]; a[2] *= 4; a[2]", 12},
+		{"let a = [1, 2]; let b = a; b[0] = 9; a[0]", 9},
+		{`let h = {"k": 1}; h["k"] = 2; h["k"]`, 2},
+		{`let h = {}; h["new"] = 3; len(h) + h["new"]`, 4},
+		{`let h = {"a": [1, 2]}; h["a"][1] += 5; h["a"][1]`, 7},
+		{"let a = [0]; let i = 0; a[i] = i = 4; a[0] + i", 8},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+
+	// hashes keep the position of a key when it is reassigned
+	evaluated := testEval(`let h = {"a": 1, "b": 2}; h["a"] = 3; h["c"] = 4; h`)
+	if evaluated.Inspect() != `{"a": 3, "b": 2, "c": 4}` {
+		t.Errorf("wrong hash. got=%s", evaluated.Inspect())
+	}
+
+	testFloatObject(t, testEval("let x = 1; x /= 2.0; x"), 0.5)
+	evaluated = testEval(`let s = "a"; s += "b"; s`)
+	if str, ok := evaluated.(*object.String); !ok || str.Value != "ab" {
+		t.Errorf("wrong string. got=%s", evaluated.Inspect())
+	}
+}
+
+func TestAssignErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 1", "1:1: assignment to undeclared identifier: x"},
+		{"let y = 0; x += 1", "1:12: assignment to undeclared identifier: x"},
+		{"len = 1", "1:1: assignment to undeclared identifier: len"},
+		{"let f = fn() { let z = 1 }; f(); z = 2", "1:34: assignment to undeclared identifier: z"},
+		{"let x = 1; x = missing", "1:16: identifier not found: missing"},
+		{"let x = 1; x += true", "1:12: type mismatch: INTEGER + BOOLEAN"},
+		{"let x = 1; x /= 0", "1:12: division by zero: 1 / 0"},
+		{"let a = [1]; a[1] = 2", "1:14: index out of range: 1 with length 1"},
+		{"let a = [1]; a[-1] += 2", "1:14: index out of range: -1 with length 1"},
+		{`let a = [1]; a["0"] = 2`, "1:14: array index must be INTEGER, got STRING"},
+		{"let h = {}; h[[1]] = 2", "1:13: unusable as hash key: ARRAY"},
+		{`let h = {}; h["k"] += 1`, "1:13: type mismatch: NULL + INTEGER"},
+		{`let s = "ab"; s[0] = "c"`, "1:15: index assignment not supported: STRING"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q", tt.input)
+			continue
+		}
+		msg := errObj.Span.Start.String() + ": " + errObj.Message
+		if msg != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, msg)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `
+// double every element
+let double = fn(a) {
+	let out = []; /* collected here */
+	for (x in a) { out = push(out, x * 2) } // one by one
+	out
+};
+double([1, 2])[1] /* second */ / 2`
+
+	testIntegerObject(t, testEval(input), 2)
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	testIntegerObject(t, testEval("let 数量 = 3; let 总和_2 = 数量 * 2; 总和_2"), 6)
+	testIntegerObject(t, testEval("let café = fn(a1, a2) { a1 - a2 }; café(5, 3)"), 2)
+
+	errObj, ok := testEval("let 名字 = 1; 名字 + true").(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+	msg := errObj.Span.Start.String() + ": " + errObj.Message
+	if msg != "1:13: type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("wrong error message. got=%q", msg)
+	}
+}
@@ -0,0 +1,491 @@
+// Package format prints programs in the canonical style: one statement per
+// line, blocks indented by a tab, a space around binary operators and only
+// the parentheses the precedences of the parser call for. Source keeps the
+// comments and the blank lines between statements, formatting its output
+// again gives the same text.
+package format
+
+import (
+	"interrupter/ast"
+	"interrupter/cst"
+	"interrupter/diag"
+	"interrupter/lexer"
+	"interrupter/parser"
+	"interrupter/token"
+	"math"
+	"strings"
+)
+
+// Error is returned for source that doesn't parse, nothing is formatted then
+type Error struct {
+	Diagnostics []diag.Diagnostic
+}
+
+func (e *Error) Error() string {
+	lines := make([]string, 0, len(e.Diagnostics))
+	for _, d := range e.Diagnostics {
+		lines = append(lines, d.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Source formats a whole file, filename is only used in diagnostics
+func Source(filename string, src []byte) ([]byte, error) {
+	p := parser.NewLossless(lexer.NewFile(filename, string(src)))
+	root := p.ParseCST()
+	if ds := p.Diagnostics(); len(ds) > 0 {
+		return nil, &Error{Diagnostics: ds}
+	}
+	pr := &printer{extents: make(map[ast.Node]token.Span)}
+	pr.measure(root)
+	pr.program(root.Program())
+	return []byte(pr.out.String()), nil
+}
+
+// Program formats prog without its comments, those are only kept by Source
+func Program(prog *ast.Program) string {
+	pr := &printer{}
+	pr.program(prog)
+	return pr.out.String()
+}
+
+type printer struct {
+	out    strings.Builder
+	indent int
+
+	// what Source knows about the input, empty for Program
+	extents  map[ast.Node]token.Span // parentheses and ; of statements included
+	comments []token.Token           // not printed yet, in source order
+	line     int                     // source line the last thing printed ends on
+}
+
+// measure records the extent of the nodes below n and collects the comments
+func (p *printer) measure(n *cst.Node) (first, last *cst.Token) {
+	for _, c := range n.Children {
+		var f, l *cst.Token
+		switch c := c.(type) {
+		case *cst.Token:
+			f, l = c, c
+			p.collect(c.Leading)
+			p.collect(c.Trailing)
+		case *cst.Node:
+			f, l = p.measure(c)
+		}
+		if f == nil {
+			continue
+		}
+		if first == nil {
+			first = f
+		}
+		last = l
+	}
+	if first == nil || n.AST == nil {
+		return first, last
+	}
+	// the parentheses around an expression are measured after it
+	if _, ok := p.extents[n.AST]; !ok || n.Paren {
+		p.extents[n.AST] = token.Span{Start: first.Pos, End: last.End}
+	}
+	return first, last
+}
+
+func (p *printer) collect(trivia []token.Token) {
+	for _, tr := range trivia {
+		if tr.Type == token.COMMENT {
+			p.comments = append(p.comments, tr)
+		}
+	}
+}
+
+func (p *printer) program(prog *ast.Program) {
+	p.statements(prog.Statements, math.MaxInt)
+	p.leading(math.MaxInt, false)
+	if p.out.Len() > 0 {
+		p.out.WriteByte('\n')
+	}
+}
+
+func (p *printer) statements(stmts []ast.Statement, limit int) {
+	for i, s := range stmts {
+		var next ast.Statement
+		if i+1 < len(stmts) {
+			next = stmts[i+1]
+		}
+		ext, ok := p.extents[s]
+		p.item(ext, ok, i == 0, p.nextStart(next, limit), func() { p.statement(s, next) })
+	}
+}
+
+// nextStart is where the comments after an item stop belonging to it: at
+// the next item or else at limit
+func (p *printer) nextStart(next ast.Node, limit int) int {
+	if ext, ok := p.extents[next]; ok && next != nil {
+		return ext.Start.Offset
+	}
+	return limit
+}
+
+// item prints one line of a list: the comments before it on lines of their
+// own, then the item followed by the comments inside it and those after it
+// on the same line that come before limit
+func (p *printer) item(ext token.Span, known bool, first bool, limit int, print func()) {
+	if known && p.leading(ext.Start.Offset, first) {
+		first = false
+	}
+	p.lineBreak(ext.Start.Line, first)
+	print()
+	if known {
+		p.line = ext.End.Line
+		p.trailing(ext.End, limit)
+	}
+}
+
+// leading prints the comments before offset on lines of their own,
+// comments sharing a source line stay together as trailing puts them
+func (p *printer) leading(offset int, first bool) bool {
+	printed, lineComment := false, false
+	for len(p.comments) > 0 && p.comments[0].Pos.Offset < offset {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		if printed && !lineComment && c.Pos.Line == p.line {
+			p.out.WriteByte(' ')
+		} else {
+			p.lineBreak(c.Pos.Line, first && !printed)
+		}
+		text := commentText(c)
+		p.out.WriteString(text)
+		lineComment = strings.HasPrefix(text, "//")
+		p.line = c.End.Line
+		printed = true
+	}
+	return printed
+}
+
+// trailing prints the comments before end and those after it on its line,
+// a line comment ends the line so whatever follows goes to the next one
+func (p *printer) trailing(end token.Position, limit int) {
+	lineComment := false
+	for len(p.comments) > 0 {
+		c := p.comments[0]
+		if c.Pos.Offset >= limit || (c.Pos.Offset >= end.Offset && c.Pos.Line != end.Line) {
+			return
+		}
+		p.comments = p.comments[1:]
+		if lineComment {
+			p.lineBreak(0, true)
+		} else {
+			p.out.WriteByte(' ')
+		}
+		text := commentText(c)
+		p.out.WriteString(text)
+		lineComment = strings.HasPrefix(text, "//")
+		if c.End.Line > p.line {
+			p.line = c.End.Line
+		}
+	}
+}
+
+// lineBreak starts a new line for something on the given source line, it
+// keeps one blank line where the source has any
+func (p *printer) lineBreak(line int, first bool) {
+	if p.out.Len() == 0 {
+		return
+	}
+	p.out.WriteByte('\n')
+	if !first && p.line > 0 && line > p.line+1 {
+		p.out.WriteByte('\n')
+	}
+	p.out.WriteString(strings.Repeat("\t", p.indent))
+}
+
+func (p *printer) hasCommentBefore(offset int) bool {
+	return len(p.comments) > 0 && p.comments[0].Pos.Offset < offset
+}
+
+func commentText(c token.Token) string {
+	return strings.TrimRight(c.Literal, " \t\r")
+}
+
+// list prints n items between open and close one per line, extent returns
+// the source extent of an item
+func (p *printer) list(open, close token.Token, n int, extent func(i int) (token.Span, bool), print func(i int)) {
+	limit := close.Pos.Offset
+	if n == 0 && !p.hasCommentBefore(limit) {
+		p.out.WriteString(open.Literal + close.Literal)
+		return
+	}
+	p.out.WriteString(open.Literal)
+	p.indent++
+	p.line = open.Pos.Line
+	// the start of item i, or limit after the last one
+	start := func(i int) int {
+		if i < n {
+			if ext, ok := extent(i); ok {
+				return ext.Start.Offset
+			}
+		}
+		return limit
+	}
+	// comments on the line of open stay there
+	p.trailing(open.End, start(0))
+	for i := 0; i < n; i++ {
+		ext, ok := extent(i)
+		p.item(ext, ok, i == 0, start(i+1), func() { print(i) })
+	}
+	p.leading(limit, n == 0)
+	p.indent--
+	p.lineBreak(0, true)
+	p.out.WriteString(close.Literal)
+	p.line = close.Pos.Line
+}
+
+func (p *printer) block(b *ast.BlockStatement) {
+	open := token.Token{Literal: "{", Pos: b.Token.Pos, End: b.Token.End}
+	close := token.Token{Literal: "}", Pos: b.Rbrace.Pos, End: b.Rbrace.End}
+	p.list(open, close, len(b.Statements), func(i int) (token.Span, bool) {
+		ext, ok := p.extents[b.Statements[i]]
+		return ext, ok
+	}, func(i int) {
+		var next ast.Statement
+		if i+1 < len(b.Statements) {
+			next = b.Statements[i+1]
+		}
+		p.statement(b.Statements[i], next)
+	})
+}
+
+func (p *printer) statement(s ast.Statement, next ast.Statement) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		p.out.WriteString("let " + s.Name.Value + " = ")
+		p.expr(s.Value)
+		p.out.WriteByte(';')
+	case *ast.ReturnStatement:
+		p.out.WriteString("return")
+		if s.ReturnValue != nil {
+			p.out.WriteByte(' ')
+			p.expr(s.ReturnValue)
+		}
+		p.out.WriteByte(';')
+	case *ast.ExpressionStatement:
+		p.expr(s.Expression)
+		// an if ends with a block and needs no ;, unless the next statement
+		// would then be read as the rest of the if expression
+		_, isIf := s.Expression.(*ast.IfExpression)
+		if !isIf || continues(next) {
+			p.out.WriteByte(';')
+		}
+	case *ast.WhileStatement:
+		p.out.WriteString("while (")
+		p.expr(s.Condition)
+		p.out.WriteString(") ")
+		p.block(s.Body)
+	case *ast.ForStatement:
+		p.out.WriteString("for (" + s.Variable.Value + " in ")
+		p.expr(s.Iterable)
+		p.out.WriteString(") ")
+		p.block(s.Body)
+	case *ast.BreakStatement:
+		p.out.WriteString("break;")
+	case *ast.ContinueStatement:
+		p.out.WriteString("continue;")
+	}
+}
+
+// continues reports whether the statement starts with a token that can
+// also continue an expression, as ( [ + and - can
+func continues(s ast.Statement) bool {
+	es, ok := s.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+	e := es.Expression
+	for {
+		var left ast.Expression
+		min := 0
+		switch n := e.(type) {
+		case *ast.PrefixExpression:
+			return parser.Precedence(n.Token.Type) > parser.LOWEST
+		case *ast.ArrayLiteral:
+			return true
+		case *ast.InfixExpression:
+			left, min = n.Left, parser.Precedence(n.Token.Type)
+		case *ast.LogicalExpression:
+			left, min = n.Left, parser.Precedence(n.Token.Type)
+		case *ast.AssignExpression:
+			left, min = n.Target, parser.ASSIGN+1
+		case *ast.CallExpression:
+			left, min = n.Function, parser.CALL
+		case *ast.IndexExpression:
+			left, min = n.Left, parser.CALL
+		default:
+			return false
+		}
+		if precedence(left) < min {
+			return true // (
+		}
+		e = left
+	}
+}
+
+// precedence is how tight e holds together, an operand binding looser
+// than its operator needs parentheses
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(e.Token.Type)
+	case *ast.LogicalExpression:
+		return parser.Precedence(e.Token.Type)
+	case *ast.AssignExpression:
+		return parser.ASSIGN
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression:
+		return parser.CALL
+	case *ast.IndexExpression:
+		return parser.INDEX
+	}
+	return math.MaxInt
+}
+
+// operand prints e in parentheses when it binds looser than min
+func (p *printer) operand(e ast.Expression, min int) {
+	if precedence(e) >= min {
+		p.expr(e)
+		return
+	}
+	p.out.WriteByte('(')
+	p.expr(e)
+	p.out.WriteByte(')')
+}
+
+// binary prints a left associative operator, the right operand needs
+// parentheses at the same precedence already
+func (p *printer) binary(left ast.Expression, operator string, prec int, right ast.Expression) {
+	p.operand(left, prec)
+	p.out.WriteString(" " + operator + " ")
+	p.operand(right, prec+1)
+}
+
+func (p *printer) expr(e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.out.WriteString(e.Value)
+	case *ast.Boolean:
+		if e.Value {
+			p.out.WriteString("true")
+		} else {
+			p.out.WriteString("false")
+		}
+	case *ast.IntegerLiteral:
+		// numbers keep their spelling, 0xff stays hexadecimal
+		p.out.WriteString(e.Token.Literal)
+	case *ast.FloatLiteral:
+		p.out.WriteString(e.Token.Literal)
+	case *ast.StringLiteral:
+		p.out.WriteString(ast.Quote(e.Value))
+	case *ast.PrefixExpression:
+		p.out.WriteString(e.Operator)
+		p.operand(e.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		p.binary(e.Left, e.Operator, parser.Precedence(e.Token.Type), e.Right)
+	case *ast.LogicalExpression:
+		p.binary(e.Left, e.Operator, parser.Precedence(e.Token.Type), e.Right)
+	case *ast.AssignExpression:
+		// right associative: a = b = c is a = (b = c)
+		p.operand(e.Target, parser.ASSIGN+1)
+		p.out.WriteString(" " + e.Operator + " ")
+		p.operand(e.Value, parser.ASSIGN)
+	case *ast.CallExpression:
+		p.operand(e.Function, parser.CALL)
+		p.out.WriteByte('(')
+		p.exprs(e.Arguments)
+		p.out.WriteByte(')')
+	case *ast.IndexExpression:
+		p.operand(e.Left, parser.CALL)
+		p.out.WriteByte('[')
+		p.expr(e.Index)
+		p.out.WriteByte(']')
+	case *ast.IfExpression:
+		p.out.WriteString("if (")
+		p.expr(e.Condition)
+		p.out.WriteString(") ")
+		p.block(e.Consequence)
+		if e.Alternative != nil {
+			p.out.WriteString(" else ")
+			p.block(e.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		params := make([]string, 0, len(e.Parameters))
+		for _, param := range e.Parameters {
+			params = append(params, param.Value)
+		}
+		p.out.WriteString("fn(" + strings.Join(params, ", ") + ") ")
+		p.block(e.Body)
+	case *ast.ArrayLiteral:
+		p.array(e)
+	case *ast.HashLiteral:
+		p.hash(e)
+	}
+}
+
+func (p *printer) exprs(es []ast.Expression) {
+	for i, e := range es {
+		if i > 0 {
+			p.out.WriteString(", ")
+		}
+		p.expr(e)
+	}
+}
+
+// literals written over several lines keep one element per line
+func multiline(open, close token.Token) bool {
+	return close.Pos.Line > open.Pos.Line
+}
+
+func (p *printer) array(a *ast.ArrayLiteral) {
+	if !multiline(a.Token, a.Rbracket) {
+		p.out.WriteByte('[')
+		p.exprs(a.Elements)
+		p.out.WriteByte(']')
+		return
+	}
+	// arrays take no comma after the last element
+	p.list(a.Token, a.Rbracket, len(a.Elements), func(i int) (token.Span, bool) {
+		ext, ok := p.extents[a.Elements[i]]
+		return ext, ok
+	}, func(i int) {
+		p.expr(a.Elements[i])
+		if i+1 < len(a.Elements) {
+			p.out.WriteByte(',')
+		}
+	})
+}
+
+func (p *printer) hash(h *ast.HashLiteral) {
+	if !multiline(h.Token, h.Rbrace) {
+		p.out.WriteByte('{')
+		for i, pair := range h.Pairs {
+			if i > 0 {
+				p.out.WriteString(", ")
+			}
+			p.pair(pair)
+		}
+		p.out.WriteByte('}')
+		return
+	}
+	p.list(h.Token, h.Rbrace, len(h.Pairs), func(i int) (token.Span, bool) {
+		key, ok := p.extents[h.Pairs[i].Key]
+		value, ok2 := p.extents[h.Pairs[i].Value]
+		return token.Span{Start: key.Start, End: value.End}, ok && ok2
+	}, func(i int) {
+		p.pair(h.Pairs[i])
+		p.out.WriteByte(',')
+	})
+}
+
+func (p *printer) pair(pair ast.HashPair) {
+	p.expr(pair.Key)
+	p.out.WriteString(": ")
+	p.expr(pair.Value)
+}
@@ -0,0 +1,227 @@
+package format
+
+import (
+	"interrupter/ast"
+	"interrupter/lexer"
+	"interrupter/parser"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func formatString(t *testing.T, input string) string {
+	t.Helper()
+	out, err := Source("test.fk", []byte(input))
+	require.NoError(t, err)
+	again, err := Source("test.fk", out)
+	require.NoError(t, err)
+	assert.Equal(t, string(out), string(again), "formatting the output again changed it")
+	return string(out)
+}
+
+func TestFormatParentheses(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"((1 + 2) * 3)", "(1 + 2) * 3;\n"},
+		{"1 + (2 * 3)", "1 + 2 * 3;\n"},
+		{"(1 + 2) + 3", "1 + 2 + 3;\n"},
+		{"1 + (2 + 3)", "1 + (2 + 3);\n"},
+		{"1 - (2 - 3)", "1 - (2 - 3);\n"},
+		{"(a == b) == c", "a == b == c;\n"},
+		{"-(a + b)", "-(a + b);\n"},
+		{"-(-a)", "--a;\n"},
+		{"(-a)[0]", "(-a)[0];\n"},
+		{"-(a[0])", "-a[0];\n"},
+		{"(f(x))[0]", "f(x)[0];\n"},
+		{"(a + b)(c)", "(a + b)(c);\n"},
+		{"(fn(x) { x })(1)", "fn(x) {\n\tx;\n}(1);\n"},
+		{"a = (b = c)", "a = b = c;\n"},
+		{"(a = b) + 1", "(a = b) + 1;\n"},
+		{"x += (y || z)", "x += y || z;\n"},
+		{"(a || b) && c", "(a || b) && c;\n"},
+		{"a || (b && c)", "a || b && c;\n"},
+		{"(flags & MASK) == MASK", "flags & MASK == MASK;\n"},
+		{"flags & (MASK == MASK)", "flags & (MASK == MASK);\n"},
+		{"(1 << 2) + 3", "(1 << 2) + 3;\n"},
+		{"!(true)", "!true;\n"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, formatString(t, tt.input), tt.input)
+	}
+}
+
+func TestFormatStatements(t *testing.T) {
+	input := `let  x=5
+let add=fn(a,b){a+b};
+if(x>1){ return add(x,1) }else{x}
+while (x < 10) { x += 1; if (x == 5) { continue } }
+for(v in [1,2,3]){ puts(v); break }
+let h = {"a": 1, "b\n": [0x1F, 1_000, 2.5]};
+let empty = fn() {};
+`
+	expected := `let x = 5;
+let add = fn(a, b) {
+	a + b;
+};
+if (x > 1) {
+	return add(x, 1);
+} else {
+	x;
+}
+while (x < 10) {
+	x += 1;
+	if (x == 5) {
+		continue;
+	}
+}
+for (v in [1, 2, 3]) {
+	puts(v);
+	break;
+}
+let h = {"a": 1, "b\n": [0x1F, 1_000, 2.5]};
+let empty = fn() {};
+`
+	assert.Equal(t, expected, formatString(t, input))
+}
+
+func TestFormatIfSemicolon(t *testing.T) {
+	// without the ; the parenthesized statement would call the if
+	input := "if (a) { b };\n(c + 1) * 2\nif (a) { b }\nd\n"
+	expected := "if (a) {\n\tb;\n};\n(c + 1) * 2;\nif (a) {\n\tb;\n}\nd;\n"
+	assert.Equal(t, expected, formatString(t, input))
+
+	input = "if (a) { b }; [1]\nif (a) { b }; -1"
+	expected = "if (a) {\n\tb;\n};\n[1];\nif (a) {\n\tb;\n};\n-1;\n"
+	assert.Equal(t, expected, formatString(t, input))
+}
+
+func TestFormatComments(t *testing.T) {
+	input := `// header
+
+let x = 1; // one
+/* before y */
+let y = 2;
+
+
+let f = fn(a) { // args
+	// inside
+	a /* inner */ + 1;
+	// at the end
+};
+if (x) {
+	y
+} // after if
+let h = {
+	"a": 1, // first
+	// second
+	"b": 2
+};
+// last
+`
+	expected := `// header
+
+let x = 1; // one
+/* before y */
+let y = 2;
+
+let f = fn(a) { // args
+	// inside
+	a + 1; /* inner */
+	// at the end
+};
+if (x) {
+	y;
+} // after if
+let h = {
+	"a": 1, // first
+	// second
+	"b": 2,
+};
+// last
+`
+	assert.Equal(t, expected, formatString(t, input))
+}
+
+func TestFormatLineCommentInsideExpression(t *testing.T) {
+	input := "let a = [1, // one\n2] ; let b = f(1, // x\n2); /* y */\n"
+	expected := "let a = [\n\t1, // one\n\t2\n];\nlet b = f(1, 2); // x\n/* y */\n"
+	assert.Equal(t, expected, formatString(t, input))
+}
+
+func TestFormatMovedCommentsStayPut(t *testing.T) {
+	input := "let a = 1 + // x\n/* y */ 2; // z\n"
+	expected := "let a = 1 + 2; // x\n/* y */ // z\n"
+	assert.Equal(t, expected, formatString(t, input))
+}
+
+// every program with comments after two nearby tokens formats the same
+// twice and keeps all its comments
+func TestFormatCommentEverywhere(t *testing.T) {
+	programs := []string{
+		"let x = (1 + 2) * 3; puts(x)",
+		"let add = fn(a, b) { a + b }; if (x > 1) { return add(x, 1) } else { x }",
+		"while (x < 10) { x += 1; if (x == 5) { continue } }\nfor (v in [1, 2]) { break }",
+		"let h = {\n\"a\": 1,\n\"b\": [\n2,\n3\n]\n};\nh[\"a\"] = -h[\"b\"][0]",
+		"if (a) { b };\n[1]\nlet f = fn() {};",
+	}
+	comments := []string{" /* c */ ", " // c\n", "\n/* c */\n\n", " /* c */ // c\n"}
+
+	count := func(s string) int { return strings.Count(s, "c */") + strings.Count(s, "// c") }
+	for _, program := range programs {
+		tokens := lexer.New(program).All()
+		for i, first := range tokens {
+			near := i + 4
+			if near > len(tokens) {
+				near = len(tokens)
+			}
+			for _, second := range tokens[i:near] {
+				for _, c1 := range comments {
+					for _, c2 := range comments {
+						at1, at2 := first.End.Offset, second.End.Offset
+						input := program[:at1] + c1 + program[at1:at2] + c2 + program[at2:]
+						if out := formatString(t, input); count(out) != count(input) {
+							t.Fatalf("input %q: lost comments, got %q", input, out)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestFormatEmptyBlockWithComment(t *testing.T) {
+	input := "while (true) {\n// todo\n}\nfn() { /* nothing */ }"
+	expected := "while (true) {\n\t// todo\n}\nfn() { /* nothing */\n};\n"
+	assert.Equal(t, expected, formatString(t, input))
+}
+
+func TestFormatParseError(t *testing.T) {
+	_, err := Source("bad.fk", []byte("let = 5;"))
+	require.Error(t, err)
+	var ferr *Error
+	require.ErrorAs(t, err, &ferr)
+	assert.NotEmpty(t, ferr.Diagnostics)
+	assert.Contains(t, err.Error(), "bad.fk:1:5")
+}
+
+func TestFormatAssignToMalformedLiteral(t *testing.T) {
+	for _, input := range []string{"0x = 1", "99999999999999999999 += 1", "(1 + ) = 2", "99999999999999999999 + 1 = 2", "-99999999999999999999 = 3"} {
+		_, err := Source("bad.fk", []byte(input))
+		var ferr *Error
+		require.ErrorAs(t, err, &ferr, input)
+		assert.NotEmpty(t, ferr.Diagnostics, input)
+	}
+}
+
+func TestProgram(t *testing.T) {
+	p := parser.New(lexer.New("let x = (1 + 2) * 3; // gone\nputs(x)"))
+	prog := p.ParseProgram()
+	require.Empty(t, p.Errors())
+	assert.Equal(t, "let x = (1 + 2) * 3;\nputs(x);\n", Program(prog))
+	assert.Equal(t, "", Program(&ast.Program{}))
+}
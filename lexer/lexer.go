@@ -1,26 +1,88 @@
 package lexer
 
 import (
+	"interrupter/diag"
 	"interrupter/token"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
-// parse string into token
+// diagnostic codes of the lexer
+const (
+	codeUnterminatedString = "L001"
+	codeInvalidEscape      = "L002"
+	codeInvalidNumber      = "L003"
+	codeUnterminatedBlock  = "L004"
+)
+
+// parse string into token. the input is read as UTF-8 one rune at a time,
+// offsets count bytes while columns count runes.
 type Lexer struct {
-	input   string
-	ch      byte
-	pos     int
-	readPos int
+	src      *source
+	filename string
+	ch       rune
+	pos      int // offset of ch
+	readPos  int // offset of the rune after ch
+	line     int // line of ch
+	col      int // column of ch
+
+	keepComments   bool
+	keepWhitespace bool
+	diagnostics    []diag.Diagnostic
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewFile("", input)
+}
+
+// NewFile is like New, filename is recorded in the position of every token
+func NewFile(filename, input string) *Lexer {
+	return newLexer(filename, &source{buf: []byte(input)})
+}
+
+func newLexer(filename string, src *source) *Lexer {
+	l := &Lexer{src: src, filename: filename, line: 1}
 	// 先要读到第一个字符
 	l.readChar()
 	return l
 }
 
+// KeepComments makes NextToken attach comments to the neighbouring tokens
+// as trivia, by default they are dropped
+func (l *Lexer) KeepComments(keep bool) {
+	l.keepComments = keep
+}
+
+// KeepWhitespace is like KeepComments for runs of whitespace, with both
+// kept the trivia and the text of the tokens add up to the whole input
+func (l *Lexer) KeepWhitespace(keep bool) {
+	l.keepWhitespace = keep
+}
+
+// Source returns the text of span as written in the input, which for
+// strings differs from the token literal. the span must have been read.
+func (l *Lexer) Source(span token.Span) string {
+	return l.text(span.Start.Offset, span.End.Offset)
+}
+
 func (l *Lexer) NextToken() token.Token {
-	l.skipWhitespace()
+	leading := l.skipTrivia()
+	start := l.position()
+	tok := l.scanToken()
+	tok.Pos = start
+	tok.End = l.position()
+	if l.keepComments || l.keepWhitespace {
+		tok.Leading = leading
+		if tok.Type != token.EOF {
+			tok.Trailing = l.trailingTrivia()
+		}
+	}
+	return tok
+}
+
+func (l *Lexer) scanToken() token.Token {
 	ch := l.curChar()
 	var tok token.Token
 	switch ch {
@@ -28,25 +90,43 @@ func (l *Lexer) NextToken() token.Token {
 		ch := l.curChar()
 		if l.peekChar() == '=' {
 			l.readChar()
-			tok = newToken(token.EQT, string([]byte{ch, l.ch}))
+			tok = newToken(token.EQT, string([]rune{ch, l.ch}))
 		} else {
 			tok = newToken(token.ASSIGN, string(ch))
 		}
 	case '!':
 		if l.peekChar() == '=' {
 			l.readChar()
-			tok = newToken(token.NOTEQT, string([]byte{ch, l.ch}))
+			tok = newToken(token.NOTEQT, string([]rune{ch, l.ch}))
 		} else {
 			tok = newToken(token.BANG, string(ch))
 		}
+	case '&':
+		if l.peekChar() == '&' {
+			l.readChar()
+			tok = newToken(token.AND, "&&")
+		} else {
+			tok = newToken(token.BITAND, string(ch))
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			l.readChar()
+			tok = newToken(token.OR, "||")
+		} else {
+			tok = newToken(token.BITOR, string(ch))
+		}
+	case '^':
+		tok = newToken(token.BITXOR, string(ch))
+	case '~':
+		tok = newToken(token.BITNOT, string(ch))
 	case '+':
-		tok = newToken(token.PLUS, string(ch))
+		tok = l.switchAssign(token.PLUS, token.PLUS_ASSIGN)
 	case '-':
-		tok = newToken(token.SUB, string(ch))
+		tok = l.switchAssign(token.SUB, token.SUB_ASSIGN)
 	case '*':
-		tok = newToken(token.MULTI, string(ch))
+		tok = l.switchAssign(token.MULTI, token.MULTI_ASSIGN)
 	case '/':
-		tok = newToken(token.DIV, string(ch))
+		tok = l.switchAssign(token.DIV, token.DIV_ASSIGN)
 	case '(':
 		tok = newToken(token.LPARENT, string(ch))
 	case ')':
@@ -55,22 +135,49 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LBRACE, string(ch))
 	case '}':
 		tok = newToken(token.RBRACE, string(ch))
+	case '[':
+		tok = newToken(token.LBRACKET, string(ch))
+	case ']':
+		tok = newToken(token.RBRACKET, string(ch))
+	case '%':
+		tok = newToken(token.MOD, string(ch))
 	case '<':
-		tok = newToken(token.LT, string(ch))
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = newToken(token.LTE, string([]rune{ch, l.ch}))
+		} else if l.peekChar() == '<' {
+			l.readChar()
+			tok = newToken(token.SHL, string([]rune{ch, l.ch}))
+		} else {
+			tok = newToken(token.LT, string(ch))
+		}
 	case '>':
-		tok = newToken(token.GT, string(ch))
+		if l.peekChar() == '=' {
+			l.readChar()
+			tok = newToken(token.GTE, string([]rune{ch, l.ch}))
+		} else if l.peekChar() == '>' {
+			l.readChar()
+			tok = newToken(token.SHR, string([]rune{ch, l.ch}))
+		} else {
+			tok = newToken(token.GT, string(ch))
+		}
 	case ';':
 		tok = newToken(token.SEMICOLON, string(ch))
 	case ',':
 		tok = newToken(token.COMMA, string(ch))
+	case ':':
+		tok = newToken(token.COLON, string(ch))
+	case '"':
+		tok.Type = token.STRING
+		tok.Literal = l.readString()
+		return tok
 	case 0:
 		return newToken(token.EOF, "")
 	default:
 		if isNumber(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Type, tok.Literal = l.readNumber()
 			return tok
-		} else if isAlpha(l.ch) {
+		} else if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookIdent(tok.Literal)
 			return tok
@@ -83,55 +190,306 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
+// switchAssign scans the operator at the current character, or its
+// compound assignment form if a = follows it
+func (l *Lexer) switchAssign(op, assign token.TokenType) token.Token {
+	if l.peekChar() == '=' {
+		ch := l.ch
+		l.readChar()
+		return newToken(assign, string([]rune{ch, l.ch}))
+	}
+	return newToken(op, string(l.ch))
+}
+
 func (l *Lexer) readChar() {
-	if l.readPos >= len(l.input) {
-		l.ch = 0
+	if l.ch == '\n' {
+		l.line++
+		l.col = 1
 	} else {
-		l.ch = l.input[l.readPos]
+		l.col++
 	}
 	l.pos = l.readPos
-	l.readPos++
+	if !l.src.fill(l.readPos+utf8.UTFMax) && l.readPos >= len(l.src.buf) {
+		l.ch = 0
+		l.readPos++
+		return
+	}
+	// invalid bytes come out as utf8.RuneError one at a time
+	r, width := utf8.DecodeRune(l.src.buf[l.readPos:])
+	l.ch = r
+	l.readPos += width
+}
+
+// position of the current char
+func (l *Lexer) position() token.Position {
+	offset := l.pos
+	if offset > len(l.src.buf) {
+		offset = len(l.src.buf)
+	}
+	return token.Position{
+		Filename: l.filename,
+		Offset:   offset,
+		Line:     l.line,
+		Column:   l.col,
+	}
+}
+
+// position of the char after the current one
+func (l *Lexer) peekPosition() token.Position {
+	pos := l.position()
+	if l.ch != 0 {
+		pos.Offset = l.readPos
+		pos.Column++
+	}
+	return pos
+}
+
+func (l *Lexer) errorf(code string, span token.Span, format string, a ...any) {
+	l.diagnostics = append(l.diagnostics, diag.Errorf(code, span, format, a...))
 }
 
-func (l *Lexer) readNumber() string {
+// Diagnostics returns the problems found in the input read so far
+func (l *Lexer) Diagnostics() []diag.Diagnostic {
+	return l.diagnostics
+}
+
+// readNumber reads an INT or a FLOAT. integers may have a base prefix:
+// 0x (hex), 0o (octal) or 0b (binary), without one they are decimal and
+// can't start with 0 unless they are 0. a float has a fraction, an exponent
+// or both: 3.14, 1e-9, 2.5E+3. the fraction needs digits on both sides of
+// the point, so .5 and 5. are not numbers. a single _ may separate digits
+// in any of them: 1_000_000, 0xFF_FF.
+func (l *Lexer) readNumber() (token.TokenType, string) {
+	start := l.position()
+	if l.ch == '0' {
+		switch l.peekChar() {
+		case 'x', 'X':
+			return token.INT, l.readPrefixedInt(start, 16, "hexadecimal")
+		case 'o', 'O':
+			return token.INT, l.readPrefixedInt(start, 8, "octal")
+		case 'b', 'B':
+			return token.INT, l.readPrefixedInt(start, 2, "binary")
+		}
+	}
+
+	typ := token.TokenType(token.INT)
+	l.readDigits(start, isNumber, false)
+
+	if l.ch == '.' {
+		typ = token.FLOAT
+		l.readChar()
+		if !isNumber(l.ch) {
+			l.errorf(codeInvalidNumber, token.Span{Start: start, End: l.position()},
+				"expected digits after decimal point")
+		}
+		l.readDigits(start, isNumber, false)
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		typ = token.FLOAT
+		l.readChar()
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+		if !isNumber(l.ch) {
+			l.errorf(codeInvalidNumber, token.Span{Start: start, End: l.position()},
+				"exponent has no digits")
+		}
+		l.readDigits(start, isNumber, false)
+	}
+
+	lit := l.text(start.Offset, l.pos)
+	// C would read 0755 as octal, rather than guess reject it
+	if digits := strings.ReplaceAll(lit, "_", ""); typ == token.INT && len(digits) > 1 && digits[0] == '0' {
+		l.errorf(codeInvalidNumber, token.Span{Start: start, End: l.position()},
+			"leading zero in decimal literal, use 0o for octal")
+	}
+	return typ, lit
+}
+
+// cur: the 0 of the prefix
+func (l *Lexer) readPrefixedInt(start token.Position, base int, name string) string {
+	l.readChar()
+	l.readChar()
+
+	// decimal digits are read in any base so that 0b102 is one bad literal
+	isDigit := isNumber
+	if base == 16 {
+		isDigit = isHexDigit
+	}
+	digits := l.readDigits(start, isDigit, true)
+	span := token.Span{Start: start, End: l.position()}
+	if strings.Trim(digits, "_") == "" {
+		l.errorf(codeInvalidNumber, span, "%s literal has no digits", name)
+	}
+	for _, d := range digits {
+		if d != '_' && digitValue(d) >= base {
+			l.errorf(codeInvalidNumber, span, "invalid digit %q in %s literal", d, name)
+			break
+		}
+	}
+	return l.text(start.Offset, l.pos)
+}
+
+// readDigits reads a run of digits and _ separators and returns it. a _
+// must sit between two digits, or right after a base prefix if
+// afterPrefix is set.
+func (l *Lexer) readDigits(start token.Position, isDigit func(rune) bool, afterPrefix bool) string {
+	pos := l.pos
+	for isDigit(l.ch) || l.ch == '_' {
+		l.readChar()
+	}
+	digits := l.text(pos, l.pos)
+
+	misplaced := strings.Contains(digits, "__") || strings.HasSuffix(digits, "_") ||
+		(!afterPrefix && strings.HasPrefix(digits, "_"))
+	if misplaced {
+		l.errorf(codeInvalidNumber, token.Span{Start: start, End: l.position()},
+			"'_' must separate successive digits")
+	}
+	return digits
+}
+
+func digitValue(c rune) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'f':
+		return int(c - 'a' + 10)
+	case c >= 'A' && c <= 'F':
+		return int(c - 'A' + 10)
+	}
+	return 16
+}
+
+// readString reads a "..." literal and returns its value with the escapes
+// resolved. a string must end on the line it starts on.
+func (l *Lexer) readString() string {
+	start := l.position()
+	var out strings.Builder
+	l.readChar() // opening "
+	for {
+		switch l.ch {
+		case '"':
+			l.readChar()
+			return out.String()
+		case 0, '\n':
+			l.errorf(codeUnterminatedString, token.Span{Start: start, End: l.position()},
+				"unterminated string literal")
+			return out.String()
+		case '\\':
+			l.readEscape(&out)
+		default:
+			out.WriteRune(l.ch)
+			l.readChar()
+		}
+	}
+}
+
+// cur: \, stops after the escape sequence
+func (l *Lexer) readEscape(out *strings.Builder) {
+	start := l.position()
+	l.readChar()
+	switch l.ch {
+	case 'n':
+		out.WriteByte('\n')
+	case 't':
+		out.WriteByte('\t')
+	case '"':
+		out.WriteByte('"')
+	case '\\':
+		out.WriteByte('\\')
+	case 'u':
+		l.readUnicodeEscape(out, start)
+		return
+	case 0, '\n':
+		// leave it to readString to report the unterminated literal
+		return
+	default:
+		l.errorf(codeInvalidEscape, token.Span{Start: start, End: l.peekPosition()},
+			"unknown escape sequence \\%c", l.ch)
+		out.WriteRune(l.ch)
+	}
+	l.readChar()
+}
+
+// cur: u of \u{...}, stops after }. up to 6 hex digits naming a unicode
+// code point that isn't a surrogate
+func (l *Lexer) readUnicodeEscape(out *strings.Builder, start token.Position) {
+	l.readChar()
+	if l.ch != '{' {
+		l.errorf(codeInvalidEscape, token.Span{Start: start, End: l.position()},
+			"expected { after \\u")
+		return
+	}
+	l.readChar()
 	pos := l.pos
-	for isNumber(l.ch) {
+	for isHexDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[pos:l.pos]
+	digits := l.text(pos, l.pos)
+	if l.ch != '}' {
+		l.errorf(codeInvalidEscape, token.Span{Start: start, End: l.position()},
+			"expected } to close \\u{%s", digits)
+		return
+	}
+	l.readChar()
+	span := token.Span{Start: start, End: l.position()}
+	if digits == "" || len(digits) > 6 {
+		l.errorf(codeInvalidEscape, span, "\\u{%s} must have 1 to 6 hex digits", digits)
+		return
+	}
+	v, _ := strconv.ParseUint(digits, 16, 32)
+	r := rune(v)
+	if !utf8.ValidRune(r) {
+		l.errorf(codeInvalidEscape, span, "\\u{%s} is not a valid unicode code point", digits)
+		return
+	}
+	out.WriteRune(r)
+}
+
+func isHexDigit(c rune) bool {
+	return isNumber(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
 }
 
-func isNumber(c byte) bool {
+// numbers are written with ASCII digits only
+func isNumber(c rune) bool {
 	return c >= '0' && c <= '9'
 }
 
-func isAlpha(c byte) bool {
-	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+// identifiers follow Go: a letter or _ followed by letters, digits and _,
+// where letters and digits are those of unicode, so 变量1 is one identifier
+func isLetter(c rune) bool {
+	return c == '_' || unicode.IsLetter(c)
 }
 
 func (l *Lexer) readIdentifier() string {
 	pos := l.pos
-	for isAlpha(l.ch) {
+	for isLetter(l.ch) || unicode.IsDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[pos:l.pos]
+	return l.text(pos, l.pos)
 }
 
-func (l *Lexer) curChar() byte {
+func (l *Lexer) curChar() rune {
 	return l.ch
 }
 
-func (l *Lexer) peekChar() byte {
-	if l.readPos >= len(l.input) {
+func (l *Lexer) peekChar() rune {
+	if !l.src.fill(l.readPos+utf8.UTFMax) && l.readPos >= len(l.src.buf) {
 		return 0
 	}
-	return l.input[l.readPos]
+	r, _ := utf8.DecodeRune(l.src.buf[l.readPos:])
+	return r
 }
 
-func (l *Lexer) skipWhitespace() {
-	for l.ch == '\r' || l.ch == '\n' || l.ch == '\t' || l.ch == ' ' {
-		l.readChar()
-	}
+// text returns the input between two offsets that were already read
+func (l *Lexer) text(start, end int) string {
+	return string(l.src.buf[start:end])
+}
+
+func isWhitespace(c rune) bool {
+	return c == '\r' || c == '\n' || c == '\t' || c == ' '
 }
 
 func newToken(t token.TokenType, l string) token.Token {
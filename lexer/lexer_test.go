@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"interrupter/token"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -10,7 +11,7 @@ import (
 func TestReadChar(t *testing.T) {
 	input := `let a = 1`
 	tables := []struct {
-		output byte
+		output rune
 	}{
 		{'l'},
 		{'e'},
@@ -24,17 +25,18 @@ func TestReadChar(t *testing.T) {
 		{0},
 	}
 
+	// New has already read the first char
 	l := New(input)
 	for _, table := range tables {
-		l.readChar()
 		assert.Equal(t, table.output, l.ch)
+		l.readChar()
 	}
 }
 
 func TestParseToken(t *testing.T) {
 	input := `
 
-	 +-/* ,;( )123{}let==abcd!=<>
+	 +-/ * ,;( )123{}let==abcd!=<>&&||<=>=%& | ^ ~<<>>+=-=*=/=
 	`
 	tables := []token.Token{
 		newToken(token.PLUS, "+"),
@@ -48,18 +50,437 @@ func TestParseToken(t *testing.T) {
 		newToken(token.INT, "123"),
 		newToken(token.LBRACE, "{"),
 		newToken(token.RBRACE, "}"),
-		newToken(token.IDENT, "let"),
+		newToken(token.LET, "let"),
 		newToken(token.EQT, "=="),
 		newToken(token.IDENT, "abcd"),
 		newToken(token.NOTEQT, "!="),
 		newToken(token.LT, "<"),
 		newToken(token.GT, ">"),
+		newToken(token.AND, "&&"),
+		newToken(token.OR, "||"),
+		newToken(token.LTE, "<="),
+		newToken(token.GTE, ">="),
+		newToken(token.MOD, "%"),
+		newToken(token.BITAND, "&"),
+		newToken(token.BITOR, "|"),
+		newToken(token.BITXOR, "^"),
+		newToken(token.BITNOT, "~"),
+		newToken(token.SHL, "<<"),
+		newToken(token.SHR, ">>"),
+		newToken(token.PLUS_ASSIGN, "+="),
+		newToken(token.SUB_ASSIGN, "-="),
+		newToken(token.MULTI_ASSIGN, "*="),
+		newToken(token.DIV_ASSIGN, "/="),
 		newToken(token.EOF, ""),
 	}
 	l := New(input)
 	for _, tb := range tables {
 		tk := l.NextToken()
-		assert.Equal(t, tk.Type, tb.Type)
-		assert.Equal(t, tk.Literal, tb.Literal)
+		assert.Equal(t, tb.Type, tk.Type)
+		assert.Equal(t, tb.Literal, tk.Literal)
+	}
+}
+
+func TestTokenPosition(t *testing.T) {
+	input := "let a = 10;\n  a + b\n"
+	tables := []struct {
+		typ   token.TokenType
+		start token.Position
+		end   token.Position
+	}{
+		{token.LET, token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{token.IDENT, token.Position{Offset: 4, Line: 1, Column: 5}, token.Position{Offset: 5, Line: 1, Column: 6}},
+		{token.ASSIGN, token.Position{Offset: 6, Line: 1, Column: 7}, token.Position{Offset: 7, Line: 1, Column: 8}},
+		{token.INT, token.Position{Offset: 8, Line: 1, Column: 9}, token.Position{Offset: 10, Line: 1, Column: 11}},
+		{token.SEMICOLON, token.Position{Offset: 10, Line: 1, Column: 11}, token.Position{Offset: 11, Line: 1, Column: 12}},
+		{token.IDENT, token.Position{Offset: 14, Line: 2, Column: 3}, token.Position{Offset: 15, Line: 2, Column: 4}},
+		{token.PLUS, token.Position{Offset: 16, Line: 2, Column: 5}, token.Position{Offset: 17, Line: 2, Column: 6}},
+		{token.IDENT, token.Position{Offset: 18, Line: 2, Column: 7}, token.Position{Offset: 19, Line: 2, Column: 8}},
+		{token.EOF, token.Position{Offset: 20, Line: 3, Column: 1}, token.Position{Offset: 20, Line: 3, Column: 1}},
+	}
+
+	l := New(input)
+	for _, tb := range tables {
+		tk := l.NextToken()
+		assert.Equal(t, tb.typ, tk.Type)
+		assert.Equal(t, tb.start, tk.Pos)
+		assert.Equal(t, tb.end, tk.End)
+	}
+}
+
+func TestTokenFilename(t *testing.T) {
+	l := NewFile("main.fk", "\n  x")
+	tk := l.NextToken()
+	assert.Equal(t, "main.fk:2:3", tk.Pos.String())
+}
+
+func TestReadString(t *testing.T) {
+	tables := []struct {
+		input   string
+		literal string
+	}{
+		{`"hello world"`, "hello world"},
+		{`""`, ""},
+		{`"a\nb\tc"`, "a\nb\tc"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"\u{48}\u{49}"`, "HI"},
+		{`"\u{4e2d}\u{1F600}"`, "中😀"},
+		{`"中文"`, "中文"},
+	}
+
+	for _, tb := range tables {
+		l := New(tb.input)
+		tk := l.NextToken()
+		assert.Equal(t, token.TokenType(token.STRING), tk.Type, tb.input)
+		assert.Equal(t, tb.literal, tk.Literal, tb.input)
+		assert.Empty(t, l.Diagnostics(), tb.input)
+		assert.Equal(t, token.TokenType(token.EOF), l.NextToken().Type, tb.input)
+	}
+}
+
+func TestReadStringErrors(t *testing.T) {
+	tables := []struct {
+		input   string
+		literal string
+		message string
+		start   int
+		end     int
+	}{
+		{`"abc`, "abc", "unterminated string literal", 0, 4},
+		{"\"abc\nd\"", "abc", "unterminated string literal", 0, 4},
+		{`"a\qb"`, "aqb", `unknown escape sequence \q`, 2, 4},
+		{`"\u48"`, "48", `expected { after \u`, 1, 3},
+		{`"\u{48"`, "", `expected } to close \u{48`, 1, 6},
+		{`"\u{}"`, "", `\u{} must have 1 to 6 hex digits`, 1, 5},
+		{`"\u{d800}"`, "", `\u{d800} is not a valid unicode code point`, 1, 9},
+		{`"\u{110000}"`, "", `\u{110000} is not a valid unicode code point`, 1, 11},
+	}
+
+	for _, tb := range tables {
+		l := New(tb.input)
+		tk := l.NextToken()
+		assert.Equal(t, token.TokenType(token.STRING), tk.Type, tb.input)
+		assert.Equal(t, tb.literal, tk.Literal, tb.input)
+		if assert.Len(t, l.Diagnostics(), 1, tb.input) {
+			d := l.Diagnostics()[0]
+			assert.Equal(t, tb.message, d.Message, tb.input)
+			assert.Equal(t, tb.start, d.Span.Start.Offset, tb.input)
+			assert.Equal(t, tb.end, d.Span.End.Offset, tb.input)
+		}
+	}
+}
+
+func TestReadNumber(t *testing.T) {
+	tables := []struct {
+		input   string
+		typ     token.TokenType
+		literal string
+	}{
+		{"0", token.INT, "0"},
+		{"123", token.INT, "123"},
+		{"3.14", token.FLOAT, "3.14"},
+		{"0.5", token.FLOAT, "0.5"},
+		{"1e-9", token.FLOAT, "1e-9"},
+		{"1E9", token.FLOAT, "1E9"},
+		{"2.5e+3", token.FLOAT, "2.5e+3"},
+		{"0e5", token.FLOAT, "0e5"},
+		{"05.5", token.FLOAT, "05.5"},
+	}
+
+	for _, tb := range tables {
+		l := New(tb.input)
+		tk := l.NextToken()
+		assert.Equal(t, tb.typ, tk.Type, tb.input)
+		assert.Equal(t, tb.literal, tk.Literal, tb.input)
+		assert.Empty(t, l.Diagnostics(), tb.input)
+		assert.Equal(t, token.TokenType(token.EOF), l.NextToken().Type, tb.input)
+	}
+}
+
+func TestReadNumberErrors(t *testing.T) {
+	tables := []struct {
+		input   string
+		literal string
+		message string
+	}{
+		{"5.", "5.", "expected digits after decimal point"},
+		{"5.e3", "5.e3", "expected digits after decimal point"},
+		{"1e", "1e", "exponent has no digits"},
+		{"1e+", "1e+", "exponent has no digits"},
+	}
+
+	for _, tb := range tables {
+		l := New(tb.input)
+		tk := l.NextToken()
+		assert.Equal(t, token.TokenType(token.FLOAT), tk.Type, tb.input)
+		assert.Equal(t, tb.literal, tk.Literal, tb.input)
+		if assert.Len(t, l.Diagnostics(), 1, tb.input) {
+			assert.Equal(t, tb.message, l.Diagnostics()[0].Message, tb.input)
+		}
+	}
+
+	// a number must start with a digit
+	l := New(".5")
+	assert.Equal(t, token.TokenType(token.ILLEGAL), l.NextToken().Type)
+}
+
+func TestReadPrefixedNumber(t *testing.T) {
+	tables := []struct {
+		input string
+		typ   token.TokenType
+	}{
+		{"0xFF", token.INT},
+		{"0Xff", token.INT},
+		{"0x_dead_BEEF", token.INT},
+		{"0b1010", token.INT},
+		{"0B1_0", token.INT},
+		{"0o755", token.INT},
+		{"0O7_7", token.INT},
+		{"1_000_000", token.INT},
+		{"1_000.000_1", token.FLOAT},
+		{"1e1_0", token.FLOAT},
+	}
+
+	for _, tb := range tables {
+		l := New(tb.input)
+		tk := l.NextToken()
+		assert.Equal(t, tb.typ, tk.Type, tb.input)
+		assert.Equal(t, tb.input, tk.Literal, tb.input)
+		assert.Empty(t, l.Diagnostics(), tb.input)
+		assert.Equal(t, token.TokenType(token.EOF), l.NextToken().Type, tb.input)
+	}
+}
+
+func TestReadPrefixedNumberErrors(t *testing.T) {
+	tables := []struct {
+		input   string
+		literal string
+		message string
+		end     int
+	}{
+		{"0x", "0x", "hexadecimal literal has no digits", 2},
+		{"0b;", "0b", "binary literal has no digits", 2},
+		{"0o", "0o", "octal literal has no digits", 2},
+		{"0b102", "0b102", "invalid digit '2' in binary literal", 5},
+		{"0o78", "0o78", "invalid digit '8' in octal literal", 4},
+		{"1__0", "1__0", "'_' must separate successive digits", 4},
+		{"10_", "10_", "'_' must separate successive digits", 3},
+		{"0xF__F", "0xF__F", "'_' must separate successive digits", 6},
+		{"1_.5", "1_.5", "'_' must separate successive digits", 2},
+		{"0755", "0755", "leading zero in decimal literal, use 0o for octal", 4},
+		{"09", "09", "leading zero in decimal literal, use 0o for octal", 2},
+		{"00", "00", "leading zero in decimal literal, use 0o for octal", 2},
+		{"0_1", "0_1", "leading zero in decimal literal, use 0o for octal", 3},
+	}
+
+	for _, tb := range tables {
+		l := New(tb.input)
+		tk := l.NextToken()
+		assert.Equal(t, tb.literal, tk.Literal, tb.input)
+		if assert.Len(t, l.Diagnostics(), 1, tb.input) {
+			d := l.Diagnostics()[0]
+			assert.Equal(t, tb.message, d.Message, tb.input)
+			assert.Equal(t, 0, d.Span.Start.Offset, tb.input)
+			assert.Equal(t, tb.end, d.Span.End.Offset, tb.input)
+		}
+	}
+}
+
+func TestSkipComments(t *testing.T) {
+	input := `// leading
+let a = 1; // trailing
+/* block
+   comment */ a / /* inline */ 2 /* no /* nesting */ */`
+
+	tables := []token.Token{
+		newToken(token.LET, "let"),
+		newToken(token.IDENT, "a"),
+		newToken(token.ASSIGN, "="),
+		newToken(token.INT, "1"),
+		newToken(token.SEMICOLON, ";"),
+		newToken(token.IDENT, "a"),
+		newToken(token.DIV, "/"),
+		newToken(token.INT, "2"),
+		newToken(token.MULTI, "*"),
+		newToken(token.DIV, "/"),
+		newToken(token.EOF, ""),
+	}
+	l := New(input)
+	for _, tb := range tables {
+		tk := l.NextToken()
+		assert.Equal(t, tb.Type, tk.Type)
+		assert.Equal(t, tb.Literal, tk.Literal)
+		assert.Nil(t, tk.Leading)
+		assert.Nil(t, tk.Trailing)
+	}
+	assert.Empty(t, l.Diagnostics())
+}
+
+func TestKeepComments(t *testing.T) {
+	input := `// about a
+// more
+let a = 1; // one
+/* b */ b /* c */ /* d */
+// end`
+
+	l := New(input)
+	l.KeepComments(true)
+
+	literals := func(tokens []token.Token) []string {
+		var out []string
+		for _, tk := range tokens {
+			assert.Equal(t, token.TokenType(token.COMMENT), tk.Type)
+			out = append(out, tk.Literal)
+		}
+		return out
+	}
+
+	let := l.NextToken()
+	assert.Equal(t, []string{"// about a", "// more"}, literals(let.Leading))
+	assert.Nil(t, let.Trailing)
+	assert.Equal(t, 2, let.Leading[1].Pos.Line)
+	assert.Equal(t, 8, let.Leading[1].End.Column)
+
+	l.NextToken() // a
+	l.NextToken() // =
+	l.NextToken() // 1
+	semi := l.NextToken()
+	assert.Equal(t, []string{"// one"}, literals(semi.Trailing))
+
+	b := l.NextToken()
+	assert.Equal(t, []string{"/* b */"}, literals(b.Leading))
+	assert.Equal(t, []string{"/* c */", "/* d */"}, literals(b.Trailing))
+
+	eof := l.NextToken()
+	assert.Equal(t, token.TokenType(token.EOF), eof.Type)
+	assert.Equal(t, []string{"// end"}, literals(eof.Leading))
+	assert.Nil(t, eof.Trailing)
+}
+
+func TestUnterminatedBlockComment(t *testing.T) {
+	l := New("a /* never\nclosed")
+	assert.Equal(t, token.TokenType(token.IDENT), l.NextToken().Type)
+	assert.Equal(t, token.TokenType(token.EOF), l.NextToken().Type)
+
+	if assert.Len(t, l.Diagnostics(), 1) {
+		d := l.Diagnostics()[0]
+		assert.Equal(t, "block comment not terminated", d.Message)
+		assert.Equal(t, 2, d.Span.Start.Offset)
+		assert.Equal(t, 17, d.Span.End.Offset)
+	}
+}
+
+func TestReadIdentifier(t *testing.T) {
+	tables := []struct {
+		input   string
+		literal string
+	}{
+		{"a1", "a1"},
+		{"_", "_"},
+		{"_tmp_2", "_tmp_2"},
+		{"snake_case", "snake_case"},
+		{"变量", "变量"},
+		{"用户名1", "用户名1"},
+		{"café", "café"},
+		{"Ωmega_٣", "Ωmega_٣"},
+		{"x+", "x"},
+		{"a·b", "a"},
+	}
+
+	for _, tb := range tables {
+		tk := New(tb.input).NextToken()
+		assert.Equal(t, token.TokenType(token.IDENT), tk.Type, tb.input)
+		assert.Equal(t, tb.literal, tk.Literal, tb.input)
+	}
+
+	// digits can't start an identifier, not even unicode ones
+	l := New("1a ٣")
+	assert.Equal(t, token.TokenType(token.INT), l.NextToken().Type)
+	assert.Equal(t, token.TokenType(token.IDENT), l.NextToken().Type)
+	assert.Equal(t, token.TokenType(token.ILLEGAL), l.NextToken().Type)
+}
+
+func TestUnicodePosition(t *testing.T) {
+	input := "let 名字 = \"héllo\";\n名字 €"
+	tables := []struct {
+		typ   token.TokenType
+		start token.Position
+		end   token.Position
+	}{
+		{token.LET, token.Position{Offset: 0, Line: 1, Column: 1}, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{token.IDENT, token.Position{Offset: 4, Line: 1, Column: 5}, token.Position{Offset: 10, Line: 1, Column: 7}},
+		{token.ASSIGN, token.Position{Offset: 11, Line: 1, Column: 8}, token.Position{Offset: 12, Line: 1, Column: 9}},
+		{token.STRING, token.Position{Offset: 13, Line: 1, Column: 10}, token.Position{Offset: 21, Line: 1, Column: 17}},
+		{token.SEMICOLON, token.Position{Offset: 21, Line: 1, Column: 17}, token.Position{Offset: 22, Line: 1, Column: 18}},
+		{token.IDENT, token.Position{Offset: 23, Line: 2, Column: 1}, token.Position{Offset: 29, Line: 2, Column: 3}},
+		{token.ILLEGAL, token.Position{Offset: 30, Line: 2, Column: 4}, token.Position{Offset: 33, Line: 2, Column: 5}},
+		{token.EOF, token.Position{Offset: 33, Line: 2, Column: 5}, token.Position{Offset: 33, Line: 2, Column: 5}},
+	}
+
+	l := New(input)
+	for _, tb := range tables {
+		tk := l.NextToken()
+		assert.Equal(t, tb.typ, tk.Type, tk.Literal)
+		assert.Equal(t, tb.start, tk.Pos, tk.Literal)
+		assert.Equal(t, tb.end, tk.End, tk.Literal)
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	l := New("a\xffb")
+	assert.Equal(t, "a", l.NextToken().Literal)
+	bad := l.NextToken()
+	assert.Equal(t, token.TokenType(token.ILLEGAL), bad.Type)
+	assert.Equal(t, 1, bad.Pos.Offset)
+	assert.Equal(t, 2, bad.End.Offset)
+	assert.Equal(t, "b", l.NextToken().Literal)
+}
+
+func TestKeepWhitespace(t *testing.T) {
+	input := "let s = \"a\\tb\";  // c\n\n  s\t/* d */\n"
+
+	l := New(input)
+	l.KeepWhitespace(true)
+	l.KeepComments(true)
+
+	var sb strings.Builder
+	for _, tk := range l.All() {
+		for _, tr := range tk.Leading {
+			sb.WriteString(tr.Literal)
+		}
+		sb.WriteString(l.Source(token.Span{Start: tk.Pos, End: tk.End}))
+		for _, tr := range tk.Trailing {
+			sb.WriteString(tr.Literal)
+		}
+	}
+	assert.Equal(t, input, sb.String())
+
+	// the line break goes to the next token
+	l.Reset()
+	l.NextToken() // let
+	l.NextToken() // s
+	l.NextToken() // =
+	str := l.NextToken()
+	assert.Equal(t, "a\tb", str.Literal)
+	assert.Equal(t, `"a\tb"`, l.Source(token.Span{Start: str.Pos, End: str.End}))
+	semi := l.NextToken()
+	if assert.Len(t, semi.Trailing, 2) {
+		assert.Equal(t, token.TokenType(token.WHITESPACE), semi.Trailing[0].Type)
+		assert.Equal(t, "  ", semi.Trailing[0].Literal)
+		assert.Equal(t, "// c", semi.Trailing[1].Literal)
+	}
+	s := l.NextToken()
+	if assert.Len(t, s.Leading, 1) {
+		assert.Equal(t, "\n\n  ", s.Leading[0].Literal)
+	}
+
+	// whitespace alone, comments are still dropped
+	l = New("a /* b */ c")
+	l.KeepWhitespace(true)
+	a := l.NextToken()
+	if assert.Len(t, a.Trailing, 2) {
+		assert.Equal(t, " ", a.Trailing[0].Literal)
+		assert.Equal(t, " ", a.Trailing[1].Literal)
 	}
 }
@@ -0,0 +1,98 @@
+package lexer
+
+import (
+	"errors"
+	"interrupter/token"
+	"io"
+)
+
+// the reader is consumed this many bytes at a time
+const chunkSize = 4096
+
+// source is the input of a lexer and of all its clones. text read from
+// the reader is kept, so a clone or a reset lexer never reads it again.
+type source struct {
+	r   io.Reader // nil once drained
+	buf []byte
+	err error // the read error that stopped reading, other than io.EOF
+}
+
+// fill reads until buf holds n bytes or the reader is drained, it reports
+// whether buf is long enough
+func (s *source) fill(n int) bool {
+	for len(s.buf) < n && s.r != nil {
+		if cap(s.buf)-len(s.buf) < chunkSize {
+			buf := make([]byte, len(s.buf), 2*cap(s.buf)+chunkSize)
+			copy(buf, s.buf)
+			s.buf = buf
+		}
+		m, err := s.r.Read(s.buf[len(s.buf):cap(s.buf)])
+		s.buf = s.buf[:len(s.buf)+m]
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				s.err = err
+			}
+			s.r = nil
+		}
+	}
+	return len(s.buf) >= n
+}
+
+// NewReader lexes the text of r, reading it only as far as the tokens
+// asked for so far need
+func NewReader(r io.Reader) *Lexer {
+	return NewFileReader("", r)
+}
+
+// NewFileReader is like NewReader, filename is recorded in the position
+// of every token
+func NewFileReader(filename string, r io.Reader) *Lexer {
+	return newLexer(filename, &source{r: r})
+}
+
+// Err returns the error that stopped reading the input early, the input
+// then appears to end there
+func (l *Lexer) Err() error {
+	return l.src.err
+}
+
+// All reads the remaining tokens, the last one is the EOF
+func (l *Lexer) All() []token.Token {
+	var tokens []token.Token
+	l.Each(func(tok token.Token) bool {
+		tokens = append(tokens, tok)
+		return true
+	})
+	return tokens
+}
+
+// Each calls fn with every remaining token up to and including the EOF,
+// it stops early when fn returns false
+func (l *Lexer) Each(fn func(token.Token) bool) {
+	for {
+		tok := l.NextToken()
+		if !fn(tok) || tok.Type == token.EOF {
+			return
+		}
+	}
+}
+
+// Clone returns a lexer that continues independently from the current
+// position of l, it can be kept as a checkpoint to lex again from
+func (l *Lexer) Clone() *Lexer {
+	c := *l
+	c.diagnostics = c.diagnostics[:len(c.diagnostics):len(c.diagnostics)]
+	return &c
+}
+
+// Reset moves l back to the start of its input and forgets its diagnostics
+func (l *Lexer) Reset() {
+	*l = Lexer{
+		src:            l.src,
+		filename:       l.filename,
+		line:           1,
+		keepComments:   l.keepComments,
+		keepWhitespace: l.keepWhitespace,
+	}
+	l.readChar()
+}
@@ -0,0 +1,145 @@
+package lexer
+
+import (
+	"errors"
+	"interrupter/token"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const streamInput = `let 名字 = "héllo\u{1F600}"; // hi
+let f = fn(x) { x * 0x_FF + 1.5e3 };
+/* block */ f(名字)`
+
+// countingReader counts the bytes handed out by r
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestReaderMatchesString(t *testing.T) {
+	expected := New(streamInput).All()
+
+	// one byte at a time splits the multibyte runes across reads
+	readers := []io.Reader{
+		strings.NewReader(streamInput),
+		iotest.OneByteReader(strings.NewReader(streamInput)),
+		iotest.HalfReader(strings.NewReader(streamInput)),
+		iotest.DataErrReader(strings.NewReader(streamInput)),
+	}
+	for _, r := range readers {
+		l := NewReader(r)
+		assert.Equal(t, expected, l.All())
+		assert.NoError(t, l.Err())
+	}
+}
+
+func TestReaderFilename(t *testing.T) {
+	tk := NewFileReader("main.fk", strings.NewReader("\n  a")).NextToken()
+	assert.Equal(t, "main.fk:2:3", tk.Pos.String())
+}
+
+func TestReaderIsLazy(t *testing.T) {
+	input := "first " + strings.Repeat("x ", 3*chunkSize)
+	r := &countingReader{r: strings.NewReader(input)}
+	l := NewReader(r)
+
+	assert.Equal(t, "first", l.NextToken().Literal)
+	assert.Equal(t, chunkSize, r.n)
+
+	tokens := l.All()
+	assert.Len(t, tokens, 3*chunkSize+1)
+	assert.Equal(t, len(input), r.n)
+}
+
+func TestAll(t *testing.T) {
+	tokens := New("a + 1").All()
+	types := []token.TokenType{token.IDENT, token.PLUS, token.INT, token.EOF}
+	if assert.Len(t, tokens, len(types)) {
+		for i, typ := range types {
+			assert.Equal(t, typ, tokens[i].Type)
+		}
+	}
+
+	// nothing is left after the EOF
+	l := New("")
+	assert.Len(t, l.All(), 1)
+	assert.Len(t, l.All(), 1)
+}
+
+func TestEach(t *testing.T) {
+	l := New("a b c d")
+	var seen []string
+	l.Each(func(tok token.Token) bool {
+		seen = append(seen, tok.Literal)
+		return tok.Literal != "b"
+	})
+	assert.Equal(t, []string{"a", "b"}, seen)
+
+	// the lexer goes on where Each stopped
+	assert.Equal(t, "c", l.NextToken().Literal)
+}
+
+func TestClone(t *testing.T) {
+	r := &countingReader{r: strings.NewReader("let a = 1 +")}
+	l := NewReader(r)
+	l.NextToken()
+	l.NextToken()
+
+	checkpoint := l.Clone()
+	rest := l.All()
+	assert.Len(t, l.Diagnostics(), 0)
+	read := r.n
+
+	// the clone lexes the same tokens again without touching the reader
+	assert.Equal(t, rest, checkpoint.All())
+	assert.Equal(t, read, r.n)
+
+	// diagnostics are kept apart
+	l = New(`"a" "b`)
+	l.NextToken()
+	c := l.Clone()
+	l.All()
+	assert.Len(t, l.Diagnostics(), 1)
+	assert.Len(t, c.Diagnostics(), 0)
+	c.All()
+	assert.Len(t, c.Diagnostics(), 1)
+}
+
+func TestReset(t *testing.T) {
+	r := &countingReader{r: strings.NewReader("a /* b */ c \"d")}
+	l := NewReader(r)
+	l.KeepComments(true)
+	first := l.All()
+	assert.Len(t, l.Diagnostics(), 1)
+	read := r.n
+
+	l.Reset()
+	assert.Empty(t, l.Diagnostics())
+	assert.Equal(t, first, l.All())
+	assert.Len(t, l.Diagnostics(), 1)
+	assert.Equal(t, read, r.n)
+}
+
+func TestReaderError(t *testing.T) {
+	failure := errors.New("disk on fire")
+	r := io.MultiReader(strings.NewReader("a b"), iotest.ErrReader(failure))
+	l := NewReader(r)
+
+	tokens := l.All()
+	if assert.Len(t, tokens, 3) {
+		assert.Equal(t, "b", tokens[1].Literal)
+		assert.Equal(t, token.TokenType(token.EOF), tokens[2].Type)
+	}
+	assert.ErrorIs(t, l.Err(), failure)
+}
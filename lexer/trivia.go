@@ -0,0 +1,84 @@
+package lexer
+
+import "interrupter/token"
+
+// comments come in two forms: // runs to the end of the line, /* runs to
+// the first */. block comments don't nest, like in Go and C, so
+// `/* a /* b */ c */` ends after b and leaves `c */` as code.
+
+// skipTrivia skips whitespace and comments in front of the next token,
+// it returns those that are kept
+func (l *Lexer) skipTrivia() []token.Token {
+	return l.readTrivia(isWhitespace)
+}
+
+// trailingTrivia reads the whitespace and comments that follow a token on
+// its line, the line break itself is left for the next token
+func (l *Lexer) trailingTrivia() []token.Token {
+	return l.readTrivia(func(c rune) bool {
+		return c == ' ' || c == '\t' || c == '\r'
+	})
+}
+
+func (l *Lexer) readTrivia(space func(rune) bool) []token.Token {
+	var trivia []token.Token
+	for {
+		switch {
+		case space(l.ch):
+			start := l.position()
+			for space(l.ch) {
+				l.readChar()
+			}
+			if l.keepWhitespace {
+				trivia = append(trivia, l.triviaToken(token.WHITESPACE, start))
+			}
+		case l.atComment():
+			c := l.readComment()
+			if l.keepComments {
+				trivia = append(trivia, c)
+			}
+		default:
+			return trivia
+		}
+	}
+}
+
+func (l *Lexer) triviaToken(typ token.TokenType, start token.Position) token.Token {
+	end := l.position()
+	return token.Token{
+		Type:    typ,
+		Literal: l.text(start.Offset, end.Offset),
+		Pos:     start,
+		End:     end,
+	}
+}
+
+func (l *Lexer) atComment() bool {
+	return l.ch == '/' && (l.peekChar() == '/' || l.peekChar() == '*')
+}
+
+// cur: the / opening the comment
+func (l *Lexer) readComment() token.Token {
+	start := l.position()
+	if l.peekChar() == '/' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.readChar()
+		}
+	} else {
+		l.readChar()
+		l.readChar()
+		for !(l.ch == '*' && l.peekChar() == '/') {
+			if l.ch == 0 {
+				l.errorf(codeUnterminatedBlock, token.Span{Start: start, End: l.position()},
+					"block comment not terminated")
+				break
+			}
+			l.readChar()
+		}
+		if l.ch != 0 {
+			l.readChar()
+			l.readChar()
+		}
+	}
+	return l.triviaToken(token.COMMENT, start)
+}
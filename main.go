@@ -1,10 +1,95 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"interrupter/diag"
+	"interrupter/format"
 	"interrupter/repl"
+	"io"
 	"os"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "fmt" {
+		os.Exit(formatFiles(os.Args[2:], os.Stdin, os.Stdout, os.Stderr))
+	}
 	repl.Start(os.Stdin, os.Stdout)
 }
+
+// formatFiles runs `fork fmt [-w] files...`, without files it formats the
+// standard input. it returns the exit code.
+func formatFiles(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	write := flags.Bool("w", false, "write the result to the file instead of the standard output")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: fork fmt [-w] files...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(stderr, "fork fmt: -w needs files to write to")
+			return 2
+		}
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return formatFile("<stdin>", src, false, stdout, stderr)
+	}
+
+	code := 0
+	for _, filename := range flags.Args() {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			code = 1
+			continue
+		}
+		if c := formatFile(filename, src, *write, stdout, stderr); c != 0 {
+			code = c
+		}
+	}
+	return code
+}
+
+func formatFile(filename string, src []byte, write bool, stdout, stderr io.Writer) int {
+	out, err := format.Source(filename, src)
+	var ferr *format.Error
+	if errors.As(err, &ferr) {
+		r := &diag.Renderer{}
+		r.Render(stderr, string(src), ferr.Diagnostics...)
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if !write {
+		_, _ = stdout.Write(out)
+		return 0
+	}
+	// leave files that are formatted already untouched
+	if bytes.Equal(src, out) {
+		return 0
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if err := os.WriteFile(filename, out, info.Mode().Perm()); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
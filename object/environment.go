@@ -0,0 +1,47 @@
+package object
+
+// Environment holds the bindings of a lexical scope, outer points to the
+// enclosing scope and is nil for the global one.
+type Environment struct {
+	store map[string]Object
+	outer *Environment
+}
+
+func NewEnvironment() *Environment {
+	return &Environment{store: make(map[string]Object)}
+}
+
+// NewEnclosedEnvironment creates a scope nested in outer
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	return env
+}
+
+// Get looks name up from the innermost scope outwards
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		return e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+// Define binds name in the current scope, shadowing any outer binding
+func (e *Environment) Define(name string, val Object) Object {
+	e.store[name] = val
+	return val
+}
+
+// Set rebinds name in the nearest scope that already declares it,
+// it reports false if name is not declared anywhere in the chain.
+func (e *Environment) Set(name string, val Object) bool {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return true
+	}
+	if e.outer != nil {
+		return e.outer.Set(name, val)
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package object
+
+import (
+	"bytes"
+	"strings"
+)
+
+// HashKey identifies a hashable value, equal values have equal keys and
+// different values different ones
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+	// strings are keyed by their content, a hash of it could collide
+	Str string
+}
+
+// Hashable is implemented by the objects that can be used as hash keys
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+func (i *IntegerObject) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (b *BooleanObject) HashKey() HashKey {
+	var v uint64
+	if b.Value {
+		v = 1
+	}
+	return HashKey{Type: b.Type(), Value: v}
+}
+
+func (s *String) HashKey() HashKey {
+	return HashKey{Type: s.Type(), Str: s.Value}
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash maps hashable keys to values and remembers the order the keys were
+// first inserted in
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	keys  []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+func (h *Hash) Type() ObjectType {
+	return HASH_OBJ
+}
+
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := h.Pairs[key.HashKey()]
+	return pair.Value, ok
+}
+
+// Set keeps the position of a key that is already there
+func (h *Hash) Set(key Hashable, value Object) {
+	hk := key.HashKey()
+	if _, ok := h.Pairs[hk]; !ok {
+		h.keys = append(h.keys, hk)
+	}
+	h.Pairs[hk] = HashPair{Key: key, Value: value}
+}
+
+func (h *Hash) Len() int {
+	return len(h.keys)
+}
+
+// Ordered returns the pairs in insertion order
+func (h *Hash) Ordered() []HashPair {
+	pairs := make([]HashPair, 0, len(h.keys))
+	for _, k := range h.keys {
+		pairs = append(pairs, h.Pairs[k])
+	}
+	return pairs
+}
+
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := make([]string, 0, len(h.keys))
+	for _, pair := range h.Ordered() {
+		pairs = append(pairs, inspectElement(pair.Key)+": "+inspectElement(pair.Value))
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
@@ -1,19 +1,41 @@
 package object
 
-import "fmt"
+import (
+	"bytes"
+	"fmt"
+	"interrupter/ast"
+	"interrupter/diag"
+	"interrupter/token"
+	"strconv"
+	"strings"
+)
 
 type ObjectType string
 
 const (
 	INTEGER_OBJ = "INTEGER"
+	FLOAT_OBJ   = "FLOAT"
 	BOOLEAN_OBJ = "BOOLEAN"
 	NULL_OBJ    = "NULL"
+	STRING_OBJ  = "STRING"
+
+	ARRAY_OBJ        = "ARRAY"
+	HASH_OBJ         = "HASH"
+	FUNCTION_OBJ     = "FUNCTION"
+	BUILTIN_OBJ      = "BUILTIN"
+	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
+	ERROR_OBJ        = "ERROR"
 )
 
 var (
 	TRUE  = &BooleanObject{Value: true}
 	FALSE = &BooleanObject{Value: false}
 	NULL  = &NullObject{}
+
+	BREAK    = &Break{}
+	CONTINUE = &Continue{}
 )
 
 func TrueOrFase(isTrue bool) *BooleanObject {
@@ -40,6 +62,25 @@ func (i *IntegerObject) Inspect() string {
 	return fmt.Sprintf("%d", i.Value)
 }
 
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
+// Inspect prints the shortest text that reads back as the same float,
+// whole numbers keep a .0 so they don't read back as integers.
+// infinities and NaN print as +Inf, -Inf and NaN.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
 type BooleanObject struct {
 	Value bool
 }
@@ -52,6 +93,18 @@ func (b *BooleanObject) Inspect() string {
 	return fmt.Sprintf("%v", b.Value)
 }
 
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType {
+	return STRING_OBJ
+}
+
+func (s *String) Inspect() string {
+	return s.Value
+}
+
 type NullObject struct{}
 
 func (n *NullObject) Type() ObjectType {
@@ -61,3 +114,138 @@ func (n *NullObject) Type() ObjectType {
 func (n *NullObject) Inspect() string {
 	return "null"
 }
+
+type Function struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment // the scope the function was defined in
+}
+
+func (f *Function) Type() ObjectType {
+	return FUNCTION_OBJ
+}
+
+func (f *Function) Inspect() string {
+	var out bytes.Buffer
+
+	params := make([]string, 0, len(f.Parameters))
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("fn(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(f.Body.String())
+	out.WriteString("\n}")
+	return out.String()
+}
+
+// ReturnValue wraps the value of a return statement while it unwinds
+// to the enclosing function call or program
+type ReturnValue struct {
+	Value Object
+}
+
+func (r *ReturnValue) Type() ObjectType {
+	return RETURN_VALUE_OBJ
+}
+
+func (r *ReturnValue) Inspect() string {
+	return r.Value.Inspect()
+}
+
+// Break and Continue unwind to the innermost loop like ReturnValue does
+// to the function, they never cross a function call
+type Break struct{}
+
+func (b *Break) Type() ObjectType {
+	return BREAK_OBJ
+}
+
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+type Continue struct{}
+
+func (c *Continue) Type() ObjectType {
+	return CONTINUE_OBJ
+}
+
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+
+// Error is a runtime error, it stops evaluation and propagates up to the caller of Eval
+type Error struct {
+	Code    string
+	Message string
+	Span    token.Span // the node that failed
+}
+
+func (e *Error) Type() ObjectType {
+	return ERROR_OBJ
+}
+
+func (e *Error) Inspect() string {
+	if e.Span.Start.IsValid() {
+		return "ERROR: " + e.Span.Start.String() + ": " + e.Message
+	}
+	return "ERROR: " + e.Message
+}
+
+func (e *Error) Diagnostic() diag.Diagnostic {
+	return diag.Diagnostic{
+		Severity: diag.Error,
+		Code:     e.Code,
+		Message:  e.Message,
+		Span:     e.Span,
+	}
+}
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType {
+	return ARRAY_OBJ
+}
+
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+
+	elements := make([]string, 0, len(a.Elements))
+	for _, el := range a.Elements {
+		elements = append(elements, inspectElement(el))
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+// strings are quoted inside containers, so that ["a, b"] and ["a", "b"] differ
+func inspectElement(obj Object) string {
+	if s, ok := obj.(*String); ok {
+		return ast.Quote(s.Value)
+	}
+	return obj.Inspect()
+}
+
+type BuiltinFunction func(args ...Object) Object
+
+// Builtin is a function implemented in Go
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType {
+	return BUILTIN_OBJ
+}
+
+func (b *Builtin) Inspect() string {
+	return "builtin function " + b.Name
+}
@@ -0,0 +1,199 @@
+package parser
+
+import (
+	"interrupter/ast"
+	"interrupter/cst"
+	"interrupter/lexer"
+	"interrupter/token"
+	"sort"
+)
+
+// NewLossless returns a parser for ParseCST, it makes l keep comments and
+// whitespace. ParseProgram works as well, the ast is then the same as New
+// would give apart from the trivia on its tokens.
+func NewLossless(l *lexer.Lexer) *Parser {
+	l.KeepComments(true)
+	l.KeepWhitespace(true)
+	return newParser(l, true)
+}
+
+// ParseCST parses the whole input into a lossless syntax tree, the root
+// stands for the ast.Program. parse errors are reported as usual, the
+// tokens of statements that failed to parse are kept as bare tokens of
+// the enclosing node, so the tree still prints back to the input.
+func (p *Parser) ParseCST() *cst.Node {
+	if !p.lossless {
+		panic("parser: ParseCST needs a parser made by NewLossless")
+	}
+	prog := p.ParseProgram()
+	b := &cstBuilder{
+		lexer:   p.lexer,
+		tokens:  p.tokens,
+		parens:  p.parens,
+		extents: make(map[ast.Node]token.Span),
+	}
+	root := &cst.Node{AST: prog}
+	b.fill(root, b.children(prog))
+	b.takeTokens(root, func(token.Token) bool { return true })
+	return root
+}
+
+// cstBuilder lays the recorded tokens over the ast: a node takes the
+// tokens inside its extent that no child takes
+type cstBuilder struct {
+	lexer   *lexer.Lexer
+	tokens  []token.Token
+	parens  map[ast.Node][]token.Span
+	extents map[ast.Node]token.Span
+	next    int // index of the next token to place
+}
+
+// fill appends to n the tokens before each child followed by the child,
+// a statement takes the ; right after it
+func (b *cstBuilder) fill(n *cst.Node, children []ast.Node) {
+	for _, child := range children {
+		start := b.outerExtent(child).Start.Offset
+		b.takeTokens(n, func(t token.Token) bool { return t.Pos.Offset < start })
+		node := b.build(child, len(b.parens[child]))
+		if _, ok := child.(ast.Statement); ok && b.next < len(b.tokens) && b.tokens[b.next].Type == token.SEMICOLON {
+			b.takeToken(node)
+		}
+		n.Children = append(n.Children, node)
+	}
+}
+
+// build makes the node for n wrapped in its innermost depth parentheses
+func (b *cstBuilder) build(n ast.Node, depth int) *cst.Node {
+	if depth > 0 {
+		span := b.parens[n][depth-1]
+		wrapper := &cst.Node{AST: n, Paren: true}
+		b.takeTokens(wrapper, func(t token.Token) bool { return t.Pos.Offset < span.Start.Offset })
+		b.takeToken(wrapper) // (
+		wrapper.Children = append(wrapper.Children, b.build(n, depth-1))
+		b.takeTokens(wrapper, func(t token.Token) bool { return t.Pos.Offset < span.End.Offset })
+		return wrapper
+	}
+
+	node := &cst.Node{AST: n}
+	end := b.extent(n).End.Offset
+	b.fill(node, b.children(n))
+	b.takeTokens(node, func(t token.Token) bool {
+		return t.Pos.Offset < end && t.Type != token.EOF
+	})
+	return node
+}
+
+func (b *cstBuilder) takeTokens(n *cst.Node, more func(token.Token) bool) {
+	for b.next < len(b.tokens) && more(b.tokens[b.next]) {
+		b.takeToken(n)
+	}
+}
+
+func (b *cstBuilder) takeToken(n *cst.Node) {
+	t := b.tokens[b.next]
+	b.next++
+	n.Children = append(n.Children, &cst.Token{
+		Token: t,
+		Text:  b.lexer.Source(token.Span{Start: t.Pos, End: t.End}),
+	})
+}
+
+// outerExtent is the extent of n including its parentheses
+func (b *cstBuilder) outerExtent(n ast.Node) token.Span {
+	if spans := b.parens[n]; len(spans) > 0 {
+		return spans[len(spans)-1]
+	}
+	return b.extent(n)
+}
+
+// extent is the span of n grown to cover its children, a child in
+// parentheses reaches out of the span of its parent, as in (a + b) * c
+func (b *cstBuilder) extent(n ast.Node) token.Span {
+	if ext, ok := b.extents[n]; ok {
+		return ext
+	}
+	ext := n.Span()
+	for _, child := range b.children(n) {
+		c := b.outerExtent(child)
+		if c.Start.Offset < ext.Start.Offset {
+			ext.Start = c.Start
+		}
+		if c.End.Offset > ext.End.Offset {
+			ext.End = c.End
+		}
+	}
+	b.extents[n] = ext
+	return ext
+}
+
+// children returns the child nodes of n in source order
+func (b *cstBuilder) children(n ast.Node) []ast.Node {
+	var children []ast.Node
+	add := func(nodes ...ast.Node) {
+		for _, c := range nodes {
+			if c != nil {
+				children = append(children, c)
+			}
+		}
+	}
+
+	switch n := n.(type) {
+	case *ast.Program:
+		for _, s := range n.Statements {
+			add(s)
+		}
+	case *ast.BlockStatement:
+		for _, s := range n.Statements {
+			add(s)
+		}
+	case *ast.LetStatement:
+		add(n.Name, n.Value)
+	case *ast.ReturnStatement:
+		if n.ReturnValue != nil {
+			add(n.ReturnValue)
+		}
+	case *ast.ExpressionStatement:
+		add(n.Expression)
+	case *ast.WhileStatement:
+		add(n.Condition, n.Body)
+	case *ast.ForStatement:
+		add(n.Variable, n.Iterable, n.Body)
+	case *ast.PrefixExpression:
+		add(n.Right)
+	case *ast.InfixExpression:
+		add(n.Left, n.Right)
+	case *ast.LogicalExpression:
+		add(n.Left, n.Right)
+	case *ast.AssignExpression:
+		add(n.Target, n.Value)
+	case *ast.IfExpression:
+		add(n.Condition, n.Consequence)
+		if n.Alternative != nil {
+			add(n.Alternative)
+		}
+	case *ast.FunctionLiteral:
+		for _, param := range n.Parameters {
+			add(param)
+		}
+		add(n.Body)
+	case *ast.CallExpression:
+		add(n.Function)
+		for _, arg := range n.Arguments {
+			add(arg)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range n.Elements {
+			add(el)
+		}
+	case *ast.IndexExpression:
+		add(n.Left, n.Index)
+	case *ast.HashLiteral:
+		for _, pair := range n.Pairs {
+			add(pair.Key, pair.Value)
+		}
+	}
+	sort.SliceStable(children, func(i, j int) bool {
+		return b.outerExtent(children[i]).Start.Offset < b.outerExtent(children[j]).Start.Offset
+	})
+	return children
+}
@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"interrupter/ast"
+	"interrupter/cst"
+	"interrupter/lexer"
+	"strings"
+	"testing"
+)
+
+func TestCSTRoundTrip(t *testing.T) {
+	inputs := []string{
+		"",
+		"  // only a comment\n",
+		"let a = 1;",
+		"  let   a=(1 + 2) * 3 ; // c\n\n/* x */ a\n",
+		"((a))",
+		"let f = fn(x, y) {\n\treturn x + y; // sum\n};\nf(1, (2))",
+		"if (a) { \"s\\n\\u{41}\" } else { b };",
+		"while (x < 3) { x += 1; if (x == 2) { break; } }\nfor (i in [1, 2]) { continue }",
+		`{"a": 1, "b": [1, 2][0], }["a"]`,
+		"let x = (fn(){ 1 })();\r\n-x",
+		"let 名字 = 0x_FF | ~1 << 2 && !true || a[0] %= 1",
+		// broken input keeps its text as well
+		"let = 5; let b = 2",
+		"a \xff b",
+		"let a = 1 /* unterminated",
+		"fn(x { x }",
+	}
+
+	for _, input := range inputs {
+		p := NewLossless(lexer.New(input))
+		root := p.ParseCST()
+		if root.String() != input {
+			t.Errorf("tree doesn't print back its input. want=%q, got=%q", input, root.String())
+		}
+	}
+}
+
+func TestCSTMatchesAST(t *testing.T) {
+	input := "let a = (1 + 2) * 3; // c\nlet f = fn(x) { x[0] };\nf([a])"
+
+	p := NewLossless(lexer.New(input))
+	root := p.ParseCST()
+	checkParserErrors(t, p)
+
+	plain := New(lexer.New(input)).ParseProgram()
+	prog := root.Program()
+	if prog == nil {
+		t.Fatalf("root.Program() is nil")
+	}
+	if prog.String() != plain.String() {
+		t.Errorf("lossless ast differs. want=%q, got=%q", plain.String(), prog.String())
+	}
+
+	if len(root.Children) != 4 {
+		t.Fatalf("root has wrong number of children. got=%d", len(root.Children))
+	}
+	for i, stmt := range prog.Statements {
+		node, ok := root.Children[i].(*cst.Node)
+		if !ok || node.AST != stmt {
+			t.Errorf("root.Children[%d] is not the node of %q. got=%+v", i, stmt.String(), root.Children[i])
+		}
+	}
+	if eof, ok := root.Children[3].(*cst.Token); !ok || eof.Type != "EOF" {
+		t.Errorf("last child of root is not EOF. got=%+v", root.Children[3])
+	}
+
+	// the statement owns its ; and the comment trailing it
+	let := root.Children[0].(*cst.Node)
+	if let.String() != "let a = (1 + 2) * 3; // c" {
+		t.Errorf("let has wrong text. got=%q", let.String())
+	}
+
+	// the parentheses wrap the node of the inner expression
+	infix := prog.Statements[0].(*ast.LetStatement).Value.(*ast.InfixExpression)
+	grouped := root.Find(infix.Left)
+	if grouped == nil || grouped.String() != "1 + 2" {
+		t.Fatalf("wrong node for the grouped expression. got=%+v", grouped)
+	}
+	product := root.Find(infix)
+	paren, ok := product.Children[0].(*cst.Node)
+	if !ok || !paren.Paren || paren.String() != "(1 + 2) " || paren.Children[1] != grouped {
+		t.Errorf("wrong paren node. got=%+v", product.Children[0])
+	}
+}
+
+func TestCSTEdit(t *testing.T) {
+	input := "let count = 1;  // start\nlet f = fn(x) {\n    count + x   /* keep */\n};\nf(count)"
+	expected := "let total = 1;  // start\nlet f = fn(x) {\n    total + x   /* keep */\n};\nf(total)"
+
+	p := NewLossless(lexer.New(input))
+	root := p.ParseCST()
+	checkParserErrors(t, p)
+
+	// rename every use of count
+	root.Walk(func(e cst.Element) bool {
+		if node, ok := e.(*cst.Node); ok {
+			if ident, ok := node.AST.(*ast.Identifier); ok && ident.Value == "count" {
+				node.Tokens()[0].Text = "total"
+			}
+		}
+		return true
+	})
+
+	if root.String() != expected {
+		t.Errorf("wrong text after the edit. want=%q, got=%q", expected, root.String())
+	}
+
+	var out strings.Builder
+	n, err := root.WriteTo(&out)
+	if err != nil || int(n) != len(expected) || out.String() != expected {
+		t.Errorf("WriteTo wrote %d bytes %q, err=%v", n, out.String(), err)
+	}
+}
+
+func TestCSTKeepsBrokenStatements(t *testing.T) {
+	input := "let = 5;\nlet b = 2;"
+
+	p := NewLossless(lexer.New(input))
+	root := p.ParseCST()
+
+	if len(p.Errors()) != 1 {
+		t.Errorf("wrong errors. got=%q", p.Errors())
+	}
+	if len(root.Program().Statements) != 1 {
+		t.Fatalf("wrong number of statements. got=%d", len(root.Program().Statements))
+	}
+
+	// the broken statement is left as bare tokens of the root
+	var bare []string
+	for _, c := range root.Children {
+		if tok, ok := c.(*cst.Token); ok {
+			bare = append(bare, tok.Text)
+		}
+	}
+	if strings.Join(bare, " ") != "let = 5 ; " {
+		t.Errorf("wrong bare tokens. got=%q", bare)
+	}
+}
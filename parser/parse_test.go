@@ -374,6 +374,102 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"a + b / c",
 			"(a + (b / c))",
 		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a == b && c != d",
+			"((a == b) && (c != d))",
+		},
+		{
+			"!a && b < c || d",
+			"(((!a) && (b < c)) || d)",
+		},
+		{
+			"a && b && c",
+			"((a && b) && c)",
+		},
+		{
+			"a = b = c",
+			"(a = (b = c))",
+		},
+		{
+			"a += b || c && d",
+			"(a += (b || (c && d)))",
+		},
+		{
+			"a[i + 1] *= 2 + 3",
+			"((a[(i + 1)]) *= (2 + 3))",
+		},
+		{
+			"x = y -= 1",
+			"(x = (y -= 1))",
+		},
+		{
+			"a <= b == b >= a",
+			"((a <= b) == (b >= a))",
+		},
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
+		{
+			"+a - +b",
+			"((+a) - (+b))",
+		},
+		{
+			"a | b ^ c & d",
+			"(a | (b ^ (c & d)))",
+		},
+		{
+			"a & b | c & d",
+			"((a & b) | (c & d))",
+		},
+		{
+			"a ^ b ^ c",
+			"((a ^ b) ^ c)",
+		},
+		{
+			"a & b << c",
+			"(a & (b << c))",
+		},
+		{
+			"a << b + c",
+			"(a << (b + c))",
+		},
+		{
+			"a >> b * c << d",
+			"((a >> (b * c)) << d)",
+		},
+		{
+			"flags & mask == mask",
+			"((flags & mask) == mask)",
+		},
+		{
+			"a | b < c",
+			"((a | b) < c)",
+		},
+		{
+			"a & b && c | d",
+			"((a & b) && (c | d))",
+		},
+		{
+			"~a & ~b",
+			"((~a) & (~b))",
+		},
+		{
+			"~a[0] << 1",
+			"((~(a[0])) << 1)",
+		},
+		{
+			"-a >> 1 >= b",
+			"(((-a) >> 1) >= b)",
+		},
 		{
 			"a + b * c + d / e - f",
 			"(((a + (b * c)) + (d / e)) - f)",
@@ -464,3 +560,1030 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestIfExpression(t *testing.T) {
+	input := `if (x < y) { x }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T",
+			stmt.Expression)
+	}
+
+	if !testInfixExpression(t, exp.Condition, "x", "<", "y") {
+		return
+	}
+
+	if len(exp.Consequence.Statements) != 1 {
+		t.Errorf("consequence is not 1 statements. got=%d\n",
+			len(exp.Consequence.Statements))
+	}
+
+	consequence, ok := exp.Consequence.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement. got=%T",
+			exp.Consequence.Statements[0])
+	}
+
+	if !testIdentifier(t, consequence.Expression, "x") {
+		return
+	}
+
+	if exp.Alternative != nil {
+		t.Errorf("exp.Alternative.Statements was not nil. got=%+v", exp.Alternative)
+	}
+}
+
+func TestIfElseExpression(t *testing.T) {
+	input := `if (x < y) { x } else { y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression. got=%T", stmt.Expression)
+	}
+
+	if !testInfixExpression(t, exp.Condition, "x", "<", "y") {
+		return
+	}
+
+	consequence, ok := exp.Consequence.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement. got=%T",
+			exp.Consequence.Statements[0])
+	}
+	if !testIdentifier(t, consequence.Expression, "x") {
+		return
+	}
+
+	if exp.Alternative == nil || len(exp.Alternative.Statements) != 1 {
+		t.Fatalf("exp.Alternative does not contain 1 statement. got=%+v", exp.Alternative)
+	}
+	alternative, ok := exp.Alternative.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement. got=%T",
+			exp.Alternative.Statements[0])
+	}
+	if !testIdentifier(t, alternative.Expression, "y") {
+		return
+	}
+}
+
+func TestFunctionLiteralParsing(t *testing.T) {
+	input := `fn(x, y) { x + y; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	function, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.FunctionLiteral. got=%T",
+			stmt.Expression)
+	}
+
+	if len(function.Parameters) != 2 {
+		t.Fatalf("function literal parameters wrong. want 2, got=%d\n",
+			len(function.Parameters))
+	}
+
+	testLiteralExpression(t, function.Parameters[0], "x")
+	testLiteralExpression(t, function.Parameters[1], "y")
+
+	if len(function.Body.Statements) != 1 {
+		t.Fatalf("function.Body.Statements has not 1 statements. got=%d\n",
+			len(function.Body.Statements))
+	}
+
+	bodyStmt, ok := function.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("function body stmt is not ast.ExpressionStatement. got=%T",
+			function.Body.Statements[0])
+	}
+
+	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
+}
+
+func TestFunctionParameterParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+	}{
+		{input: "fn() {};", expectedParams: []string{}},
+		{input: "fn(x) {};", expectedParams: []string{"x"}},
+		{input: "fn(x, y, z) {};", expectedParams: []string{"x", "y", "z"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		function := stmt.Expression.(*ast.FunctionLiteral)
+
+		if len(function.Parameters) != len(tt.expectedParams) {
+			t.Errorf("length parameters wrong. want %d, got=%d\n",
+				len(tt.expectedParams), len(function.Parameters))
+		}
+
+		for i, ident := range tt.expectedParams {
+			testLiteralExpression(t, function.Parameters[i], ident)
+		}
+	}
+}
+
+func TestFunctionParameterErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(1) {}", `1:4: expected parameter name, got INT "1" instead`},
+		{"fn(x,) {}", `1:6: expected parameter name, got ) ")" instead`},
+		{"fn(x y) {}", "1:6: expected , or ) in parameter list, got IDENT instead"},
+		{"fn(x {}", "1:6: expected , or ) in parameter list, got { instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("input %q: expected parser errors, got none", tt.input)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("input %q: wrong error. want=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestCallExpressionParsing(t *testing.T) {
+	input := "add(1, 2 * 3, 4 + 5);"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.CallExpression. got=%T",
+			stmt.Expression)
+	}
+
+	if !testIdentifier(t, exp.Function, "add") {
+		return
+	}
+
+	if len(exp.Arguments) != 3 {
+		t.Fatalf("wrong length of arguments. got=%d", len(exp.Arguments))
+	}
+
+	testLiteralExpression(t, exp.Arguments[0], 1)
+	testInfixExpression(t, exp.Arguments[1], 2, "*", 3)
+	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
+}
+
+func TestBareReturnAndEmptyCall(t *testing.T) {
+	l := lexer.New("return; f()")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d",
+			len(program.Statements))
+	}
+	ret, ok := program.Statements[0].(*ast.ReturnStatement)
+	if !ok || ret.ReturnValue != nil {
+		t.Fatalf("expected bare return statement. got=%s", program.Statements[0])
+	}
+	call, ok := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.CallExpression)
+	if !ok || len(call.Arguments) != 0 {
+		t.Fatalf("expected call without arguments. got=%s", program.Statements[1])
+	}
+}
+
+func TestParserErrorPosition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"add(1, 2", "1:9: expected next token to be ), got EOF instead"},
+		{"let = 1", "1:5: expected next token to be IDENT, got = instead"},
+		{"let a = 1;\n  * 2", "2:3: no prefix parse function for * found"},
+		{"if (a) { b", "1:11: expected } to close block, got EOF instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("input %q: expected parser errors, got none", tt.input)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("input %q: wrong error. want=%q, got=%q", tt.input, tt.expected, errors[0])
+		}
+	}
+}
+
+func TestNodeSpan(t *testing.T) {
+	tests := []struct {
+		input string
+		start int
+		end   int
+	}{
+		{"a + b * c", 0, 9},
+		{"  -x", 2, 4},
+		{"let a = (1 + 2)", 0, 14},
+		{"add(1, 2)", 0, 9},
+		{"fn(x) { x }", 0, 11},
+		{"if (a) { b } else { c }", 0, 23},
+		{"return 1 + 2;", 0, 12},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		span := program.Statements[0].Span()
+		if span.Start.Offset != tt.start || span.End.Offset != tt.end {
+			t.Errorf("input %q: wrong span. want=[%d, %d), got=[%d, %d)", tt.input,
+				tt.start, tt.end, span.Start.Offset, span.End.Offset)
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	l := lexer.New("if (a) { add(1, 2 }")
+	p := New(l)
+	p.ParseProgram()
+
+	ds := p.Diagnostics()
+	if len(ds) == 0 {
+		t.Fatalf("expected diagnostics, got none")
+	}
+	d := ds[0]
+	if d.Code != codeUnexpectedToken {
+		t.Errorf("wrong code. want=%s, got=%s", codeUnexpectedToken, d.Code)
+	}
+	if d.Span.Start.Column != 19 || d.Span.End.Column != 20 {
+		t.Errorf("wrong span. got=%d-%d", d.Span.Start.Column, d.Span.End.Column)
+	}
+	if d.Fix == nil || d.Fix.Replacement != ")" || d.Fix.Span.Start.Column != 18 {
+		t.Errorf("wrong fix. got=%+v", d.Fix)
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedErrors []string
+		expectedProg   string
+	}{
+		{
+			"let = 1; let b = 2; let c 3; c + 1",
+			[]string{
+				"1:5: expected next token to be IDENT, got = instead",
+				"1:27: expected next token to be =, got INT instead",
+			},
+			"let b = 2(c + 1)",
+		},
+		{
+			"let a = 1\nlet b = * 2\nreturn a + ;\na",
+			[]string{
+				"2:9: no prefix parse function for * found",
+				"3:12: no prefix parse function for ; found",
+			},
+			"let a = 1a",
+		},
+		{
+			"if (a { b } let c = 1",
+			[]string{"1:7: expected next token to be ), got { instead"},
+			"let c = 1",
+		},
+		{
+			"let f = fn(x) { let = 1; x + ; x }; f(1)",
+			[]string{
+				"1:21: expected next token to be IDENT, got = instead",
+				"1:30: no prefix parse function for ; found",
+			},
+			"f(1)",
+		},
+		{
+			"} let a = 1",
+			[]string{"1:1: no prefix parse function for } found"},
+			"let a = 1",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expectedErrors) {
+			t.Errorf("input %q: wrong number of errors. want=%q, got=%q",
+				tt.input, tt.expectedErrors, errors)
+			continue
+		}
+		for i, msg := range tt.expectedErrors {
+			if errors[i] != msg {
+				t.Errorf("input %q: wrong error. want=%q, got=%q", tt.input, msg, errors[i])
+			}
+		}
+		if program.String() != tt.expectedProg {
+			t.Errorf("input %q: wrong partial program. want=%q, got=%q",
+				tt.input, tt.expectedProg, program.String())
+		}
+	}
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello \"world\"\n";`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.Value != "hello \"world\"\n" {
+		t.Errorf("literal.Value not %q. got=%q", "hello \"world\"\n", literal.Value)
+	}
+	if literal.String() != `"hello \"world\"\n"` {
+		t.Errorf("literal.String() does not quote back. got=%s", literal.String())
+	}
+}
+
+func TestUnterminatedStringError(t *testing.T) {
+	l := lexer.New("let a = 1;\nlet b = \"abc")
+	p := New(l)
+	program := p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) != 1 || errors[0] != "2:9: unterminated string literal" {
+		t.Fatalf("wrong errors. got=%q", errors)
+	}
+	if program.String() != "let a = 1" {
+		t.Errorf("wrong partial program. got=%q", program.String())
+	}
+}
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3, fn(x) { x }]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, _ := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 4 {
+		t.Fatalf("len(array.Elements) not 4. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+	if _, ok := array.Elements[3].(*ast.FunctionLiteral); !ok {
+		t.Errorf("array.Elements[3] not ast.FunctionLiteral. got=%T", array.Elements[3])
+	}
+}
+
+func TestParsingEmptyArrayLiteral(t *testing.T) {
+	l := lexer.New("[]")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, _ := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+	if len(array.Elements) != 0 {
+		t.Errorf("len(array.Elements) not 0. got=%d", len(array.Elements))
+	}
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, _ := program.Statements[0].(*ast.ExpressionStatement)
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+}
+
+func TestIndexPrecedence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
+		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{"-a[0]", "(-(a[0]))"},
+		{"f(x)[0][1]", "((f(x)[0])[1])"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	input := `{"one": 1, "two": 2, 3: true, false: 0 + 1,}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 4 {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	if hash.String() != `{"one": 1, "two": 2, 3: true, false: (0 + 1)}` {
+		t.Errorf("pairs not kept in source order. got=%s", hash.String())
+	}
+	testIntegerLiteral(t, hash.Pairs[0].Value, 1)
+	testIntegerLiteral(t, hash.Pairs[2].Key, 3)
+	testBooleanLiteral(t, hash.Pairs[2].Value, true)
+	testInfixExpression(t, hash.Pairs[3].Value, 0, "+", 1)
+}
+
+func TestParsingEmptyHashLiteral(t *testing.T) {
+	l := lexer.New("let h = {}")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	hash, ok := program.Statements[0].(*ast.LetStatement).Value.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", program.Statements[0])
+	}
+	if len(hash.Pairs) != 0 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+}
+
+func TestHashLiteralVersusBlock(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`if (a) { b } else { {"k": 1} }`, `ifa belse {"k": 1}`},
+		{`fn() { {} }`, `fn() {}`},
+		{`{"f": fn(x) { x }}["f"](1)`, `({"f": fn(x) x}["f"])(1)`},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestHashLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"a" 1}`, "1:6: expected next token to be :, got INT instead"},
+		{`{"a": 1 "b": 2}`, "1:9: expected next token to be ,, got STRING instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("input %q: wrong errors. want=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"1e-9", 1e-9},
+		{"2.5E+3", 2500},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+		if literal.TokenLiteral() != tt.input {
+			t.Errorf("literal.TokenLiteral not %s. got=%s", tt.input, literal.TokenLiteral())
+		}
+	}
+}
+
+func TestFloatLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1e999", `1:1: could not parse "1e999" as float`},
+		{"let a = 1.;", "1:9: expected digits after decimal point"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("input %q: wrong errors. want=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestIntegerLiteralBases(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0xFF", 255},
+		{"0x_ff_ff", 65535},
+		{"0b1010", 10},
+		{"0B1111_0000", 240},
+		{"0o755", 493},
+		{"0O17", 15},
+		{"1_000_000", 1000000},
+		{"0x7FFF_FFFF_FFFF_FFFF", 9223372036854775807},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("input %q: literal.Value not %d. got=%d", tt.input, tt.expected, literal.Value)
+		}
+		if literal.TokenLiteral() != tt.input {
+			t.Errorf("literal.TokenLiteral not %s. got=%s", tt.input, literal.TokenLiteral())
+		}
+	}
+}
+
+func TestMalformedIntegerLiteralErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = 0x;", "1:9: hexadecimal literal has no digits"},
+		{"let a = 0b12;", "1:9: invalid digit '2' in binary literal"},
+		{"let a = 0o9;", "1:9: invalid digit '9' in octal literal"},
+		{"let a = 1__0;", "1:9: '_' must separate successive digits"},
+		{"let a = 0x8000_0000_0000_0000;", `1:9: could not parse "0x8000_0000_0000_0000" as integer`},
+		{"let a = 010 + 1;", "1:9: leading zero in decimal literal, use 0o for octal"},
+		{"let a = 0755;", "1:9: leading zero in decimal literal, use 0o for octal"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("input %q: wrong errors. want=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+// a statement that starts with a malformed literal is dropped, the partial
+// program holds the clean statements only
+func TestMalformedLiteralStartingStatement(t *testing.T) {
+	tests := []struct {
+		input        string
+		expected     string
+		expectedProg string
+	}{
+		{"0x", "1:1: hexadecimal literal has no digits", ""},
+		{"1e", "1:1: exponent has no digits", ""},
+		{"1__0 + 2", "1:1: '_' must separate successive digits", ""},
+		{"let a = 1; 0x", "1:12: hexadecimal literal has no digits", "let a = 1"},
+		{"0b2; let a = 1", "1:1: invalid digit '2' in binary literal", "let a = 1"},
+		{"\"abc", "1:1: unterminated string literal", ""},
+		{"let a = 1;\n\"abc\nlet b = 2", "2:1: unterminated string literal", "let a = 1let b = 2"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("input %q: wrong errors. want=%q, got=%q", tt.input, tt.expected, errors)
+		}
+		if program.String() != tt.expectedProg {
+			t.Errorf("input %q: wrong partial program. want=%q, got=%q",
+				tt.input, tt.expectedProg, program.String())
+		}
+	}
+}
+
+func TestLogicalExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		operator string
+	}{
+		{"a && b;", "&&"},
+		{"a || b;", "||"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.LogicalExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.LogicalExpression. got=%T", stmt.Expression)
+		}
+		if exp.Operator != tt.operator {
+			t.Errorf("exp.Operator is not '%s'. got=%s", tt.operator, exp.Operator)
+		}
+		if !testIdentifier(t, exp.Left, "a") || !testIdentifier(t, exp.Right, "b") {
+			return
+		}
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < y) { x; break; }; done`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			2, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got=%T",
+			program.Statements[0])
+	}
+	if !testInfixExpression(t, stmt.Condition, "x", "<", "y") {
+		return
+	}
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("body is not 2 statements. got=%d\n", len(stmt.Body.Statements))
+	}
+	if _, ok := stmt.Body.Statements[1].(*ast.BreakStatement); !ok {
+		t.Errorf("Statements[1] is not ast.BreakStatement. got=%T", stmt.Body.Statements[1])
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	input := `for (item in [1, 2]) { if (item > 1) { continue } item }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForStatement. got=%T",
+			program.Statements[0])
+	}
+	if !testIdentifier(t, stmt.Variable, "item") {
+		return
+	}
+	if _, ok := stmt.Iterable.(*ast.ArrayLiteral); !ok {
+		t.Errorf("stmt.Iterable is not ast.ArrayLiteral. got=%T", stmt.Iterable)
+	}
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("body is not 2 statements. got=%d\n", len(stmt.Body.Statements))
+	}
+	if stmt.String() != "for (item in [1, 2]) if(item > 1) continueitem" {
+		t.Errorf("stmt.String() wrong. got=%q", stmt.String())
+	}
+}
+
+func TestLoopControlErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"break;", []string{"1:1: break outside loop"}},
+		{"let a = 1; continue", []string{"1:12: continue outside loop"}},
+		{"if (true) { break }", []string{"1:13: break outside loop"}},
+		{"while (true) { let f = fn() { break; }; }", []string{"1:31: break outside loop"}},
+		{"for (x in y) { fn() { continue } }; break", []string{"1:23: continue outside loop", "1:37: break outside loop"}},
+		{"for (x y) {}", []string{"1:8: expected next token to be IN, got IDENT instead"}},
+		{"while true {}", []string{"1:7: expected next token to be (, got TRUE instead"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("input %q: wrong number of errors. want=%q, got=%q", tt.input, tt.expected, errors)
+			continue
+		}
+		for i, msg := range tt.expected {
+			if errors[i] != msg {
+				t.Errorf("input %q: wrong error. want=%q, got=%q", tt.input, msg, errors[i])
+			}
+		}
+	}
+
+	// nested loops and blocks inside a loop are fine
+	valid := []string{
+		"while (true) { if (x) { break } else { continue } }",
+		"for (x in y) { while (x) { break; } continue; }",
+		"while (a) { let f = fn() { for (x in y) { break } }; break }",
+	}
+	for _, input := range valid {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		checkParserErrors(t, p)
+	}
+}
+
+func TestAssignExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		operator string
+		target   string
+		value    int64
+	}{
+		{"x = 5;", "=", "x", 5},
+		{"x += 1;", "+=", "x", 1},
+		{"x -= 2;", "-=", "x", 2},
+		{"x *= 3;", "*=", "x", 3},
+		{"x /= 4;", "/=", "x", 4},
+		{"arr[0] = 6;", "=", "(arr[0])", 6},
+		{`h["k"] += 7;`, "+=", `(h["k"])`, 7},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.AssignExpression. got=%T", stmt.Expression)
+		}
+		if exp.Operator != tt.operator {
+			t.Errorf("exp.Operator is not '%s'. got=%s", tt.operator, exp.Operator)
+		}
+		if exp.Target.String() != tt.target {
+			t.Errorf("exp.Target is not %s. got=%s", tt.target, exp.Target.String())
+		}
+		testIntegerLiteral(t, exp.Value, tt.value)
+	}
+}
+
+func TestInvalidAssignTarget(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 = 2;", "1:1: cannot assign to 1"},
+		{"a + b = c;", "1:1: cannot assign to (a + b)"},
+		{"let x = 1; f() += 1;", "1:12: cannot assign to f()"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("input %q: wrong errors. want=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestAssignToMalformedLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0x = 1", "1:1: hexadecimal literal has no digits"},
+		{"99999999999999999999 += 1", `1:1: could not parse "99999999999999999999" as integer`},
+		{"(1 + ) = 2", "1:6: no prefix parse function for ) found"},
+		{"99999999999999999999 + 1 = 2", `1:1: could not parse "99999999999999999999" as integer`},
+		{"-99999999999999999999 = 3", `1:2: could not parse "99999999999999999999" as integer`},
+	}
+
+	// only the first error matters, a broken target must not add its own
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("input %q: wrong errors. want=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// the answer
+let x = 42; // trailing
+/* a /* b */ x + 1 // done`
+
+	l := lexer.New(input)
+	l.KeepComments(true)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+	if program.String() != "let x = 42(x + 1)" {
+		t.Errorf("program.String() wrong. got=%q", program.String())
+	}
+
+	let := program.Statements[0].(*ast.LetStatement)
+	if len(let.Token.Leading) != 1 || let.Token.Leading[0].Literal != "// the answer" {
+		t.Errorf("let has wrong leading comments. got=%+v", let.Token.Leading)
+	}
+
+	infix := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.InfixExpression)
+	x := infix.Left.(*ast.Identifier)
+	if len(x.Token.Leading) != 1 || x.Token.Leading[0].Literal != "/* a /* b */" {
+		t.Errorf("x has wrong leading comments. got=%+v", x.Token.Leading)
+	}
+	one := infix.Right.(*ast.IntegerLiteral)
+	if len(one.Token.Trailing) != 1 || one.Token.Trailing[0].Literal != "// done" {
+		t.Errorf("1 has wrong trailing comments. got=%+v", one.Token.Trailing)
+	}
+}
+
+func TestUnterminatedCommentError(t *testing.T) {
+	l := lexer.New("let x = 1; /* oops")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	expected := "1:12: block comment not terminated"
+	if len(errors) != 1 || errors[0] != expected {
+		t.Errorf("wrong errors. want=%q, got=%q", expected, errors)
+	}
+}
+
+func TestUnicodeIdentifierErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let 变量 5;", "1:8: expected next token to be =, got INT instead"},
+		{"let 变量 = (1;", "1:12: expected next token to be ), got ; instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("input %q: wrong errors. want=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package parser
 import (
 	"fmt"
 	"interrupter/ast"
+	"interrupter/diag"
 	"interrupter/lexer"
 	"interrupter/token"
 	"interrupter/xlog"
@@ -10,37 +11,113 @@ import (
 	"strconv"
 )
 
-// 运算符优先级
+// diagnostic codes of the parser
+const (
+	codeUnexpectedToken  = "P001"
+	codeNoPrefixParseFn  = "P002"
+	codeInvalidInteger   = "P003"
+	codeUnclosedBlock    = "P004"
+	codeInvalidParameter = "P005"
+	codeInvalidFloat     = "P006"
+	codeOutsideLoop      = "P007"
+	codeInvalidTarget    = "P008"
+)
+
+// tokens that peekError can suggest inserting
+var delimiters = map[token.TokenType]struct{}{
+	token.LPARENT:   {},
+	token.RPARENT:   {},
+	token.LBRACE:    {},
+	token.RBRACE:    {},
+	token.RBRACKET:  {},
+	token.ASSIGN:    {},
+	token.SEMICOLON: {},
+	token.COMMA:     {},
+	token.COLON:     {},
+}
+
+// keywords a statement starts with, synchronize stops in front of them
+var statementKeywords = map[token.TokenType]struct{}{
+	token.LET:      {},
+	token.RETURN:   {},
+	token.WHILE:    {},
+	token.FOR:      {},
+	token.BREAK:    {},
+	token.CONTINUE: {},
+}
+
+// 运算符优先级, from loosest to tightest. The bitwise operators bind
+// tighter than comparisons, as in Python, so `flags & MASK == MASK` means
+// `(flags & MASK) == MASK` rather than C's `flags & (MASK == MASK)`
 const (
 	_ int = iota
 	LOWEST
-	EQUALS      // ==
-	LESSGREATER // > or <
-	SUM         // +
-	PRODUCT     // *
-	PREFIX      // -X or !X
+	ASSIGN      // = += -= *= /=
+	OR          // ||
+	AND         // &&
+	EQUALS      // == !=
+	LESSGREATER // > < >= <=
+	BITOR       // |
+	BITXOR      // ^
+	BITAND      // &
+	SHIFT       // << >>
+	SUM         // + -
+	PRODUCT     // * / %
+	PREFIX      // -X !X +X ~X
 	CALL        // myFunction(X)
+	INDEX       // array[index]
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQT:     EQUALS,
-	token.NOTEQT:  EQUALS,
-	token.LT:      LESSGREATER,
-	token.GT:      LESSGREATER,
-	token.PLUS:    SUM,
-	token.SUB:     SUM,
-	token.DIV:     PRODUCT,
-	token.MULTI:   PRODUCT,
-	token.LPARENT: CALL,
+	token.ASSIGN:       ASSIGN,
+	token.PLUS_ASSIGN:  ASSIGN,
+	token.SUB_ASSIGN:   ASSIGN,
+	token.MULTI_ASSIGN: ASSIGN,
+	token.DIV_ASSIGN:   ASSIGN,
+	token.OR:           OR,
+	token.AND:          AND,
+	token.EQT:          EQUALS,
+	token.NOTEQT:       EQUALS,
+	token.LT:           LESSGREATER,
+	token.GT:           LESSGREATER,
+	token.LTE:          LESSGREATER,
+	token.GTE:          LESSGREATER,
+	token.BITOR:        BITOR,
+	token.BITXOR:       BITXOR,
+	token.BITAND:       BITAND,
+	token.SHL:          SHIFT,
+	token.SHR:          SHIFT,
+	token.PLUS:         SUM,
+	token.SUB:          SUM,
+	token.DIV:          PRODUCT,
+	token.MULTI:        PRODUCT,
+	token.MOD:          PRODUCT,
+	token.LPARENT:      CALL,
+	token.LBRACKET:     INDEX,
 }
 
 // parse statement
 type (
 	Parser struct {
-		lexer     *lexer.Lexer
-		curToken  token.Token
-		peekToken token.Token
-		errors    []string
+		lexer       *lexer.Lexer
+		curToken    token.Token
+		peekToken   token.Token
+		diagnostics []diag.Diagnostic
+		// diagnostics the lexer reported while reading peekToken
+		peekDiagnostics []diag.Diagnostic
+		// number of diagnostics the lexer reported for curToken, they are
+		// in diagnostics already
+		curDiagnostics int
+		// the left operand passed to the current infix parse fn reported errors
+		leftBroken bool
+		// number of loops around the current token within the current function,
+		// break and continue are only allowed when it's positive
+		loopDepth int
+
+		// lossless mode keeps what ParseCST needs besides the ast
+		lossless bool
+		tokens   []token.Token
+		parens   map[ast.Node][]token.Span // innermost first
 
 		prefixParseFns map[token.TokenType]prefixParseFn
 		infixParseFns  map[token.TokenType]infixParseFn
@@ -49,12 +126,23 @@ type (
 	infixParseFn  func(ast.Expression) ast.Expression
 )
 
+// New reads the first tokens right away, so options of l such as
+// KeepComments must be set before. kept comments stay on the tokens
+// stored in the ast nodes.
 func New(l *lexer.Lexer) *Parser {
+	return newParser(l, false)
+}
+
+func newParser(l *lexer.Lexer, lossless bool) *Parser {
 	p := &Parser{
 		lexer:          l,
+		lossless:       lossless,
 		prefixParseFns: make(map[token.TokenType]prefixParseFn),
 		infixParseFns:  make(map[token.TokenType]infixParseFn),
 	}
+	if lossless {
+		p.parens = make(map[ast.Node][]token.Span)
+	}
 	// assign value to curToken and peekToken
 	p.nextToken()
 	p.nextToken()
@@ -62,29 +150,65 @@ func New(l *lexer.Lexer) *Parser {
 	// register prefix expression function
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.TRUE, p.parseBoolean)
 	p.registerPrefix(token.FALSE, p.parseBoolean)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.SUB, p.parsePrefixExpression)
+	p.registerPrefix(token.PLUS, p.parsePrefixExpression)
+	p.registerPrefix(token.BITNOT, p.parsePrefixExpression)
 	p.registerPrefix(token.LPARENT, p.parseGroupedExpression)
+	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.FN, p.parseFunctionLiteral)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	// blocks are only parsed where if and fn expect them, so a { starting an
+	// expression is always a hash literal
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
 	// register infix expression function
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.SUB, p.parseInfixExpression)
 	p.registerInfix(token.MULTI, p.parseInfixExpression)
 	p.registerInfix(token.DIV, p.parseInfixExpression)
+	p.registerInfix(token.MOD, p.parseInfixExpression)
 	p.registerInfix(token.EQT, p.parseInfixExpression)
 	p.registerInfix(token.NOTEQT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
+	p.registerInfix(token.LTE, p.parseInfixExpression)
+	p.registerInfix(token.GTE, p.parseInfixExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SUB_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MULTI_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.DIV_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.AND, p.parseLogicalExpression)
+	p.registerInfix(token.OR, p.parseLogicalExpression)
+	p.registerInfix(token.BITAND, p.parseInfixExpression)
+	p.registerInfix(token.BITOR, p.parseInfixExpression)
+	p.registerInfix(token.BITXOR, p.parseInfixExpression)
+	p.registerInfix(token.SHL, p.parseInfixExpression)
+	p.registerInfix(token.SHR, p.parseInfixExpression)
 	p.registerInfix(token.LPARENT, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
 
 	return p
 }
 
+// diagnostics of the lexer are reported once their token becomes the current one,
+// so that they count against the statement the token belongs to
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
+	p.diagnostics = append(p.diagnostics, p.peekDiagnostics...)
+	p.curDiagnostics = len(p.peekDiagnostics)
+
+	n := len(p.lexer.Diagnostics())
 	p.peekToken = p.lexer.NextToken()
+	p.peekDiagnostics = p.lexer.Diagnostics()[n:]
+	if p.lossless && (len(p.tokens) == 0 || p.tokens[len(p.tokens)-1].Type != token.EOF) {
+		p.tokens = append(p.tokens, p.peekToken)
+	}
 }
 
 func (p *Parser) registerPrefix(t token.TokenType, fn prefixParseFn) {
@@ -120,32 +244,97 @@ func (p *Parser) PrintAllToken(out io.Writer) {
 	}
 }
 
+// ParseProgram parses the whole input. it doesn't stop at the first error,
+// the program returned then holds the statements that parsed cleanly.
 func (p *Parser) ParseProgram() *ast.Program {
 	statements := []ast.Statement{}
 	prog := &ast.Program{}
 	for !p.curTokenAs(token.EOF) {
-		stmt := p.parseStatement()
-		if stmt == nil {
-			xlog.Debugf("parse program statement is nil\n")
-			return prog
+		stmt := p.parseStatementRecover()
+		if stmt != nil {
+			statements = append(statements, stmt)
 		}
-		statements = append(statements, stmt)
 		p.nextToken()
 	}
 	prog.Statements = statements
 	return prog
 }
 
+// parseStatementRecover drops a statement that reported errors and skips
+// to its end, so that parsing can go on with the next one
+func (p *Parser) parseStatementRecover() ast.Statement {
+	// the lexer's diagnostics for the first token count against the statement
+	n := len(p.diagnostics) - p.curDiagnostics
+	stmt := p.parseStatement()
+	if stmt == nil || len(p.diagnostics) > n {
+		xlog.Debugf("drop broken statement at %s\n", p.curToken.Pos)
+		p.synchronize()
+		return nil
+	}
+	return stmt
+}
+
+// synchronize moves to the last token of the broken statement: a ; or the
+// token before a statement keyword or a closing }. braces opened while
+// skipping are skipped as a whole.
+func (p *Parser) synchronize() {
+	depth := 0
+	for !p.curTokenAs(token.EOF) {
+		switch p.curToken.Type {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth > 0 {
+				depth--
+			}
+		}
+		if depth == 0 {
+			if p.curTokenAs(token.SEMICOLON) {
+				return
+			}
+			if _, ok := statementKeywords[p.peekToken.Type]; ok {
+				return
+			}
+			if p.peekTokenAs(token.RBRACE) || p.peekTokenAs(token.EOF) {
+				return
+			}
+		}
+		p.nextToken()
+	}
+}
+
+// the result is a nil interface if the statement could not be parsed
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	// let statement
 	case token.LET:
-		return p.parseLetStatement()
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
 	case token.RETURN:
-		return p.parseReturnStatement()
+		if stmt := p.parseReturnStatement(); stmt != nil {
+			return stmt
+		}
+	case token.WHILE:
+		if stmt := p.parseWhileStatement(); stmt != nil {
+			return stmt
+		}
+	case token.FOR:
+		if stmt := p.parseForStatement(); stmt != nil {
+			return stmt
+		}
+	case token.BREAK:
+		if stmt := p.parseBreakStatement(); stmt != nil {
+			return stmt
+		}
+	case token.CONTINUE:
+		if stmt := p.parseContinueStatement(); stmt != nil {
+			return stmt
+		}
 	default:
 		return p.parseExpressionStatement()
 	}
+	return nil
 }
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
@@ -176,6 +365,14 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
+	// bare return
+	if p.peekTokenAs(token.SEMICOLON) || p.peekTokenAs(token.RBRACE) || p.peekTokenAs(token.EOF) {
+		if p.peekTokenAs(token.SEMICOLON) {
+			p.nextToken()
+		}
+		return stmt
+	}
+
 	p.nextToken()
 	stmt.ReturnValue = p.parseExpression(LOWEST)
 
@@ -185,6 +382,86 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+	if !p.expectPeek(token.LPARENT) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPARENT) {
+		return nil
+	}
+	if stmt.Body = p.parseLoopBody(); stmt.Body == nil {
+		return nil
+	}
+	return stmt
+}
+
+// for (x in iterable) { ... }
+func (p *Parser) parseForStatement() *ast.ForStatement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+	if !p.expectPeek(token.LPARENT) {
+		return nil
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPARENT) {
+		return nil
+	}
+	if stmt.Body = p.parseLoopBody(); stmt.Body == nil {
+		return nil
+	}
+	return stmt
+}
+
+// peek: {, stops at the } or the ; following it
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	p.loopDepth++
+	body := p.parseBlockStatement()
+	p.loopDepth--
+	if body != nil && p.peekTokenAs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return body
+}
+
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+	if p.loopDepth == 0 {
+		p.errorf(codeOutsideLoop, tokenSpan(p.curToken), "break outside loop")
+		return nil
+	}
+	if p.peekTokenAs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+	if p.loopDepth == 0 {
+		p.errorf(codeOutsideLoop, tokenSpan(p.curToken), "continue outside loop")
+		return nil
+	}
+	if p.peekTokenAs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
 func (p *Parser) parseExpressionStatement() ast.Statement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 	stmt.Expression = p.parseExpression(LOWEST)
@@ -201,32 +478,37 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		return nil
 	}
 
+	n := len(p.diagnostics) - p.curDiagnostics
 	leftExp := prefix()
 	for !p.peekTokenAs(token.SEMICOLON) && precedence < p.peekPrecedence() {
 		infix, ok := p.infixParseFns[p.peekToken.Type]
 		if !ok {
 			return leftExp
 		}
+		p.leftBroken = len(p.diagnostics) > n
 		p.nextToken()
 		leftExp = infix(leftExp)
 	}
 	return leftExp
 }
 
-// peekToken的运算符优先级
-func (p *Parser) peekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
+// Precedence returns how tight the infix operator t binds, LOWEST for a
+// token that isn't one
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
 		return p
 	}
 	return LOWEST
 }
 
+// peekToken的运算符优先级
+func (p *Parser) peekPrecedence() int {
+	return Precedence(p.peekToken.Type)
+}
+
 // curToken的运算符优先级
 func (p *Parser) curPrecedence() int {
-	if p, ok := precedences[p.curToken.Type]; ok {
-		return p
-	}
-	return LOWEST
+	return Precedence(p.curToken.Type)
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
@@ -241,14 +523,32 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	il := &ast.IntegerLiteral{Token: p.curToken}
 	v, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		if p.curDiagnostics == 0 {
+			p.errorf(codeInvalidInteger, tokenSpan(p.curToken), "could not parse %q as integer", p.curToken.Literal)
+		}
 		return nil
 	}
 	il.Value = v
 	return il
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	fl := &ast.FloatLiteral{Token: p.curToken}
+	v, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		if p.curDiagnostics == 0 {
+			p.errorf(codeInvalidFloat, tokenSpan(p.curToken), "could not parse %q as float", p.curToken.Literal)
+		}
+		return nil
+	}
+	fl.Value = v
+	return fl
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	pe := &ast.PrefixExpression{Token: p.curToken, Operator: p.curToken.Literal}
 	p.nextToken()
@@ -268,15 +568,175 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return ie
 }
 
+// assignment is right associative, `a = b = 1` assigns 1 to both
+func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+	// the prefix failed, which is reported already
+	if left == nil {
+		return nil
+	}
+	switch left.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		// a target that is broken itself was reported already, and its
+		// missing parts can't be printed
+		if !p.leftBroken {
+			p.errorf(codeInvalidTarget, left.Span(), "cannot assign to %s", left.String())
+		}
+		return nil
+	}
+	ae := &ast.AssignExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Target:   left,
+	}
+	p.nextToken()
+	ae.Value = p.parseExpression(ASSIGN - 1)
+	return ae
+}
+
+// && and || get their own node, the evaluator may skip the right operand
+func (p *Parser) parseLogicalExpression(left ast.Expression) ast.Expression {
+	le := &ast.LogicalExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Left:     left,
+	}
+	precedence := p.curPrecedence()
+	p.nextToken()
+	le.Right = p.parseExpression(precedence)
+	return le
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	lparent := p.curToken
 	p.nextToken()
 	exp := p.parseExpression(LOWEST)
 	if !p.expectPeek(token.RPARENT) {
 		return nil
 	}
+	if p.lossless && exp != nil {
+		p.parens[exp] = append(p.parens[exp], token.Span{Start: lparent.Pos, End: p.curToken.End})
+	}
 	return exp
 }
 
+func (p *Parser) parseIfExpression() ast.Expression {
+	exp := &ast.IfExpression{Token: p.curToken}
+	if !p.expectPeek(token.LPARENT) {
+		return nil
+	}
+
+	p.nextToken()
+	exp.Condition = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPARENT) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	exp.Consequence = p.parseBlockStatement()
+
+	if p.peekTokenAs(token.ELSE) {
+		p.nextToken()
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		exp.Alternative = p.parseBlockStatement()
+	}
+	return exp
+}
+
+// cur: {, stops at the matching }
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: p.curToken}
+	block.Statements = []ast.Statement{}
+
+	p.nextToken()
+	for !p.curTokenAs(token.RBRACE) && !p.curTokenAs(token.EOF) {
+		stmt := p.parseStatementRecover()
+		if stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+		p.nextToken()
+	}
+	if !p.curTokenAs(token.RBRACE) {
+		d := p.errorf(codeUnclosedBlock, tokenSpan(p.curToken),
+			"expected %s to close block, got %s instead", token.RBRACE, p.curToken.Type)
+		d.Notes = append(d.Notes, fmt.Sprintf("block opened at %s", block.Token.Pos))
+		d.Fix = insertFix(p.curToken.Pos, token.RBRACE)
+		return nil
+	}
+	block.Rbrace = p.curToken
+	return block
+}
+
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+	fl := &ast.FunctionLiteral{Token: p.curToken}
+	if !p.expectPeek(token.LPARENT) {
+		return nil
+	}
+
+	params := p.parseFunctionParameters()
+	if params == nil {
+		return nil
+	}
+	fl.Parameters = params
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	// loops outside the function can't be broken from inside it
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	fl.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
+	if fl.Body == nil {
+		return nil
+	}
+	return fl
+}
+
+// cur: (, stops at ). returns nil if the list is malformed
+func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+	idents := []*ast.Identifier{}
+
+	if p.peekTokenAs(token.RPARENT) {
+		p.nextToken()
+		return idents
+	}
+
+	if !p.expectParameter() {
+		return nil
+	}
+	idents = append(idents, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+
+	for p.peekTokenAs(token.COMMA) {
+		p.nextToken()
+		if !p.expectParameter() {
+			return nil
+		}
+		idents = append(idents, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
+	}
+
+	if !p.peekTokenAs(token.RPARENT) {
+		p.errorf(codeInvalidParameter, tokenSpan(p.peekToken), "expected %s or %s in parameter list, got %s instead",
+			token.COMMA, token.RPARENT, p.peekToken.Type)
+		return nil
+	}
+	p.nextToken()
+	return idents
+}
+
+func (p *Parser) expectParameter() bool {
+	if !p.peekTokenAs(token.IDENT) {
+		p.errorf(codeInvalidParameter, tokenSpan(p.peekToken), "expected parameter name, got %s %q instead",
+			p.peekToken.Type, p.peekToken.Literal)
+		return false
+	}
+	p.nextToken()
+	return true
+}
+
 // func (p *Parser) parseInfixGroupedExpression(left ast.Expression) ast.Expression {
 // 	ie := &ast.InfixExpression{
 // 		Token:    p.curToken,
@@ -298,26 +758,32 @@ func (p *Parser) parseCallExpression(left ast.Expression) ast.Expression {
 		Token:    p.curToken,
 		Function: left,
 	}
-	args := p.parseCallArguments()
+	args := p.parseExpressionList(token.RPARENT)
 	if args == nil {
 		return nil
 	}
 	call.Arguments = args
+	call.Rparent = p.curToken
 	return call
 }
 
-func (p *Parser) parseCallArguments() []ast.Expression {
-	var exps []ast.Expression
+// parseExpressionList parses comma separated expressions up to end, which
+// call arguments and array literals share. cur: the opening token, stops at end
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	exps := []ast.Expression{}
 
-	// cur: (
-	if p.peekTokenAs(token.RPARENT) {
+	if p.peekTokenAs(end) {
 		p.nextToken()
 		return exps
 	}
 
 	// cur: arg1
 	p.nextToken()
-	exps = append(exps, p.parseExpression(LOWEST))
+	exp := p.parseExpression(LOWEST)
+	if exp == nil {
+		return nil
+	}
+	exps = append(exps, exp)
 
 	for p.peekTokenAs(token.COMMA) {
 		p.nextToken()
@@ -329,23 +795,108 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 		exps = append(exps, exp)
 	}
 
-	if !p.expectPeek(token.RPARENT) {
+	if !p.expectPeek(end) {
 		return nil
 	}
 	return exps
 }
 
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	elements := p.parseExpressionList(token.RBRACKET)
+	if elements == nil {
+		return nil
+	}
+	array.Elements = elements
+	array.Rbracket = p.curToken
+	return array
+}
+
+// cur: {, stops at }
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken, Pairs: []ast.HashPair{}}
+
+	for !p.peekTokenAs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+		if key == nil {
+			return nil
+		}
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		if value == nil {
+			return nil
+		}
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		if !p.peekTokenAs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+	hash.Rbrace = p.curToken
+	return hash
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+	if exp.Index == nil {
+		return nil
+	}
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	exp.Rbracket = p.curToken
+	return exp
+}
+
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.errorf(codeNoPrefixParseFn, tokenSpan(p.curToken), "no prefix parse function for %s found", t)
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
+	d := p.errorf(codeUnexpectedToken, tokenSpan(p.peekToken), "expected next token to be %s, got %s instead",
 		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	// a missing delimiter most likely belongs right after the last good token
+	if _, ok := delimiters[t]; ok {
+		d.Fix = insertFix(p.curToken.End, t)
+	}
 }
 
+// the returned diagnostic may be amended until the next error is reported
+func (p *Parser) errorf(code string, span token.Span, format string, a ...any) *diag.Diagnostic {
+	p.diagnostics = append(p.diagnostics, diag.Errorf(code, span, format, a...))
+	return &p.diagnostics[len(p.diagnostics)-1]
+}
+
+// Errors returns the diagnostics in their short "position: message" form
 func (p *Parser) Errors() []string {
-	return p.errors
+	var errors []string
+	for _, d := range p.diagnostics {
+		errors = append(errors, d.String())
+	}
+	return errors
+}
+
+func (p *Parser) Diagnostics() []diag.Diagnostic {
+	return p.diagnostics
+}
+
+func tokenSpan(t token.Token) token.Span {
+	return token.Span{Start: t.Pos, End: t.End}
+}
+
+func insertFix(pos token.Position, t token.TokenType) *diag.Fix {
+	return &diag.Fix{
+		Message:     fmt.Sprintf("insert %s", t),
+		Span:        token.Span{Start: pos, End: pos},
+		Replacement: string(t),
+	}
 }
@@ -3,31 +3,52 @@ package repl
 import (
 	"bufio"
 	"fmt"
+	"interrupter/diag"
 	"interrupter/evaluator"
 	"interrupter/lexer"
+	"interrupter/object"
 	"interrupter/parser"
 	"io"
 )
 
+// number of earlier lines whose source diagnostics can still show, errors
+// pointing further back are printed without it
+const historySize = 1000
+
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 	prompt := ">> "
 	fmt.Fprint(out, "Enter in Fork Language!\n")
-	for {
+	// bindings live across lines
+	env := object.NewEnvironment()
+	e := evaluator.New()
+	e.Out = out
+	// every line is its own file, so errors raised by functions defined on
+	// earlier lines can still show the right source, for the last
+	// historySize lines
+	history := map[string]string{}
+	for n := 1; ; n++ {
 		fmt.Fprint(out, prompt)
 		if !scanner.Scan() {
 			return
 		}
 		line := scanner.Text()
-		l := lexer.New(line)
+		filename := inputName(n)
+		history[filename] = line
+		delete(history, inputName(n-historySize))
+		l := lexer.NewFile(filename, line)
 		p := parser.New(l)
 		// p.PrintAllToken(out)
 		prog := p.ParseProgram()
 		if p.Errors() != nil {
-			printParserErrors(out, p.Errors())
+			printDiagnostics(out, history, p.Diagnostics()...)
+			continue
+		}
+		obj := e.Eval(prog, env)
+		if err, ok := obj.(*object.Error); ok {
+			printDiagnostics(out, history, err.Diagnostic())
 			continue
 		}
-		obj := evaluator.Eval(prog)
 		if obj != nil {
 			_, _ = io.WriteString(out, obj.Inspect())
 			_, _ = io.WriteString(out, "\n")
@@ -35,11 +56,13 @@ func Start(in io.Reader, out io.Writer) {
 	}
 }
 
-func printParserErrors(out io.Writer, errors []string) {
-	io.WriteString(out, "parse error: \n")
-	for _, err := range errors {
-		io.WriteString(out, "\t")
-		io.WriteString(out, err)
-		io.WriteString(out, "\n")
+func printDiagnostics(out io.Writer, history map[string]string, ds ...diag.Diagnostic) {
+	r := &diag.Renderer{}
+	for _, d := range ds {
+		r.Render(out, history[d.Span.Start.Filename], d)
 	}
 }
+
+func inputName(n int) string {
+	return fmt.Sprintf("input#%d", n)
+}
@@ -1,33 +1,62 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
 const (
 	EOF     = "EOF"
 	ILLEGAL = "ILLEGAL"
 
-	INT = "INT"
+	COMMENT    = "COMMENT"
+	WHITESPACE = "WHITESPACE"
+
+	INT    = "INT"
+	FLOAT  = "FLOAT"
+	STRING = "STRING"
+
+	ASSIGN       = "="
+	PLUS_ASSIGN  = "+="
+	SUB_ASSIGN   = "-="
+	MULTI_ASSIGN = "*="
+	DIV_ASSIGN   = "/="
 
-	ASSIGN = "="
 	EQT    = "=="
 	NOTEQT = "!="
 
-	GT = ">"
-	LT = "<"
+	GT  = ">"
+	LT  = "<"
+	GTE = ">="
+	LTE = "<="
+
+	AND = "&&"
+	OR  = "||"
+
+	BITAND = "&"
+	BITOR  = "|"
+	BITXOR = "^"
+	BITNOT = "~"
+	SHL    = "<<"
+	SHR    = ">>"
 
 	BANG  = "!"
 	PLUS  = "+"
 	SUB   = "-"
 	MULTI = "*"
 	DIV   = "/"
+	MOD   = "%"
 
 	LPARENT = "("
 	RPARENT = ")"
 	LBRACE  = "{"
 	RBRACE  = "}"
 
+	LBRACKET = "["
+	RBRACKET = "]"
+
 	SEMICOLON = ";"
 	COMMA     = ","
+	COLON     = ":"
 
 	// keywords
 	TRUE   = "TRUE"
@@ -38,6 +67,12 @@ const (
 	ELSE   = "ELSE"
 	FN     = "FN"
 	RETURN = "RETURN"
+
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
 )
 
 var keywords = map[string]TokenType{
@@ -48,11 +83,61 @@ var keywords = map[string]TokenType{
 	"false":  FALSE,
 	"else":   ELSE,
 	"return": RETURN,
+
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
 }
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // first character of the token
+	End     Position // just past the last character of the token
+
+	// trivia around the token: comments and whitespace, only filled in when
+	// the lexer keeps them. Leading holds the trivia between the previous
+	// token and this one, Trailing what follows this token on its line.
+	Leading  []Token
+	Trailing []Token
+}
+
+// Position is a location in the source, Offset counts bytes from 0,
+// Line and Column count from 1
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+// the zero Position is invalid, it's used for nodes made outside the parser
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// file:line:column, file is omitted when unknown
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	s := fmt.Sprintf("%d:%d", p.Line, p.Column)
+	if p.Filename != "" {
+		s = p.Filename + ":" + s
+	}
+	return s
+}
+
+// Span is the half-open range [Start, End) of the source
+type Span struct {
+	Start Position
+	End   Position
+}
+
+func (s Span) String() string {
+	return s.Start.String()
 }
 
 func LookIdent(key string) TokenType {